@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type client struct {
+	baseURL string
+	token string
+	http *http.Client
+}
+
+type snippet struct {
+	ID int `json:"id"`
+	Title string `json:"title"`
+	Language string `json:"language"`
+	URL string `json:"url"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type newSnippet struct {
+	Title string `json:"title"`
+	Content string `json:"content"`
+	Language string `json:"language"`
+	Expires int `json:"expires"`
+}
+
+func newClient(baseURL, token string) *client {
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token: token,
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) do(method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+
+	return res, nil
+}
+
+// responseError turns an error response from the server into a readable error.
+func responseError(res *http.Response) error {
+	var envelope struct {
+		Error any `json:"error"`
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Error == nil {
+		return fmt.Errorf("server returned %s", res.Status)
+	}
+
+	switch e := envelope.Error.(type) {
+	case string:
+		return fmt.Errorf("server returned %s: %s", res.Status, e)
+	case map[string]any:
+		msgs := []string{}
+		for field, msg := range e {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", field, msg))
+		}
+		return fmt.Errorf("server returned %s: %s", res.Status, strings.Join(msgs, "; "))
+	}
+
+	return fmt.Errorf("server returned %s", res.Status)
+}
+
+func (c *client) create(s newSnippet) (*snippet, error) {
+	if c.token == "" {
+		return nil, errors.New("an API token is required, generate one at /account/token")
+	}
+
+	res, err := c.do(http.MethodPost, "/api/snippets", s)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var envelope struct {
+		Snippet snippet `json:"snippet"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return nil, err
+	}
+
+	return &envelope.Snippet, nil
+}
+
+func (c *client) list() ([]snippet, error) {
+	if c.token == "" {
+		return nil, errors.New("an API token is required, generate one at /account/token")
+	}
+
+	res, err := c.do(http.MethodGet, "/api/snippets", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	var envelope struct {
+		Snippets []snippet `json:"snippets"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return nil, err
+	}
+
+	return envelope.Snippets, nil
+}
+
+// raw copies the content of the snippet to w. With a token it goes through
+// the API, which also serves the user's own private snippets.
+func (c *client) raw(id int, w io.Writer) error {
+	path := fmt.Sprintf("/snippet/raw/%d", id)
+	if c.token != "" {
+		path = fmt.Sprintf("/api/snippets/%d/raw", id)
+	}
+
+	res, err := c.do(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+func TestClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/snippets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid or missing API token"}`))
+			return
+		}
+		var s newSnippet
+		json.NewDecoder(r.Body).Decode(&s)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"snippet": snippet{ID: 7, Title: s.Title, URL: "http://example.com/snippet/view/7"}})
+	})
+	mux.HandleFunc("GET /api/snippets", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"snippets": []snippet{{ID: 1}, {ID: 2}}})
+	})
+	mux.HandleFunc("GET /snippet/raw/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("package main\n"))
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	t.Run("Create", func(t *testing.T) {
+		s, err := newClient(ts.URL, "secret").create(newSnippet{Title: "hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, s.ID, 7)
+		assert.Equal(t, s.Title, "hello")
+	})
+
+	t.Run("Create with bad token", func(t *testing.T) {
+		_, err := newClient(ts.URL, "wrong").create(newSnippet{Title: "hello"})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		assert.Equal(t, err.Error(), "server returned 401 Unauthorized: invalid or missing API token")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		snippets, err := newClient(ts.URL, "secret").list()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, len(snippets), 2)
+	})
+
+	t.Run("Raw", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := newClient(ts.URL, "").raw(3, &buf); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, buf.String(), "package main\n")
+
+		err := newClient(ts.URL, "").raw(4, &buf)
+		assert.Equal(t, err.Error(), "server returned 404 Not Found")
+	})
+}
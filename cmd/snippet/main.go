@@ -0,0 +1,179 @@
+// Command snippet is a command-line client for snippetbox.
+//
+//	snippet create [-title t] [-expires days] [-lang language] [file...]
+//	snippet get <id>
+//	snippet list
+//
+// The server and API token are read from the -server and -token flags, or
+// from the SNIPPETBOX_URL and SNIPPETBOX_TOKEN environment variables.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `usage: snippet [-server url] [-token token] <command> [arguments]
+
+commands:
+  create [-title t] [-expires 1|7|365] [-lang language] [file...]
+        create a snippet from each file, or from stdin if no file is given
+  get <id>
+        print the raw content of a snippet
+  list
+        list your snippets
+`
+
+// extensionLanguages maps file extensions to the languages the server accepts.
+var extensionLanguages = map[string]string{
+	".go": "go",
+	".py": "python",
+	".js": "javascript",
+	".sh": "shell",
+	".bash": "shell",
+	".sql": "sql",
+	".json": "json",
+	".yml": "yaml",
+	".yaml": "yaml",
+	".html": "html",
+	".css": "css",
+	".md": "markdown",
+}
+
+func main() {
+	server := flag.String("server", envOr("SNIPPETBOX_URL", "http://localhost:8888"), "snippetbox server URL")
+	token := flag.String("token", os.Getenv("SNIPPETBOX_TOKEN"), "API token")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := newClient(*server, *token)
+
+	var err error
+	switch cmd, args := flag.Arg(0), flag.Args()[1:]; cmd {
+	case "create":
+		err = runCreate(c, args)
+	case "get":
+		err = runGet(c, args)
+	case "list":
+		err = runList(c, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "snippet:", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func runCreate(c *client, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	title := fs.String("title", "", "snippet title (defaults to the file name)")
+	expires := fs.Int("expires", 365, "days until the snippet expires: 1, 7 or 365")
+	lang := fs.String("lang", "", "snippet language (guessed from the file extension)")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if *title == "" {
+			return fmt.Errorf("-title is required when reading from stdin")
+		}
+		return createOne(c, newSnippet{
+			Title: *title,
+			Content: string(content),
+			Language: *lang,
+			Expires: *expires,
+		})
+	}
+
+	for _, path := range fs.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		s := newSnippet{
+			Title: *title,
+			Content: string(content),
+			Language: *lang,
+			Expires: *expires,
+		}
+		if s.Title == "" {
+			s.Title = filepath.Base(path)
+		}
+		if s.Language == "" {
+			s.Language = extensionLanguages[strings.ToLower(filepath.Ext(path))]
+		}
+
+		if err := createOne(c, s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func createOne(c *client, s newSnippet) error {
+	created, err := c.create(s)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(created.URL)
+	return nil
+}
+
+func runGet(c *client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: snippet get <id>")
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil || id < 1 {
+		return fmt.Errorf("invalid snippet id %q", args[0])
+	}
+
+	return c.raw(id, os.Stdout)
+}
+
+func runList(c *client, args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: snippet list")
+	}
+
+	snippets, err := c.list()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tCREATED\tEXPIRES")
+	for _, s := range snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Title, s.Language,
+			s.Created.Format("2006-01-02"), s.Expires.Format("2006-01-02"))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+func (a *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	data := a.newTemplateData(r)
+	a.render(w, http.StatusOK, "admin.tmpl", data)
+}
+
+func (a *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	page, offset := pageParams(r)
+	users, err := a.users.All(pageSize+1, offset)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(users))
+	if len(users) > pageSize {
+		users = users[:pageSize]
+	}
+	data.Users = users
+	a.render(w, http.StatusOK, "admin_users.tmpl", data)
+}
+
+func (a *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	a.adminSetUserDisabled(w, r, true)
+}
+
+func (a *application) adminUserEnablePost(w http.ResponseWriter, r *http.Request) {
+	a.adminSetUserDisabled(w, r, false)
+}
+
+func (a *application) adminSetUserDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	targetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	target, err := a.users.Get(targetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	// admins can't lock each other (or themselves) out
+	if target.Role == models.RoleAdmin {
+		a.sessionManager.Put(r.Context(), "flash", "Admin accounts can't be disabled")
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	if err := a.users.SetDisabled(targetID, disabled); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	event, flash := models.AuditAdminUserEnable, "The account of %s has been enabled"
+	if disabled {
+		event, flash = models.AuditAdminUserDisable, "The account of %s has been disabled"
+
+		if err := a.destroyUserSessions(targetID, ""); err != nil {
+			a.serverError(w, err)
+			return
+		}
+		if err := a.tokens.DeleteAllForUser(targetID); err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
+	adminID := a.sessionManager.GetInt(r.Context(), "id")
+	err = a.logAudit(r, adminID, event, fmt.Sprintf("user %d (%s)", target.ID, target.Email))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", fmt.Sprintf(flash, target.Email))
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+func (a *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	page, offset := pageParams(r)
+	snippets, err := a.snippets.All(pageSize+1, offset)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(snippets))
+	if len(snippets) > pageSize {
+		snippets = snippets[:pageSize]
+	}
+	data.Snippets = snippets
+	a.render(w, http.StatusOK, "admin_snippets.tmpl", data)
+}
+
+func (a *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	err := a.snippets.Delete(snippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	adminID := a.sessionManager.GetInt(r.Context(), "id")
+	err = a.logAudit(r, adminID, models.AuditAdminSnippetDelete, fmt.Sprintf("snippet %d", snippetID))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The snippet has been removed")
+	http.Redirect(w, r, "/admin/snippets", http.StatusSeeOther)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type apiSnippetCreateInput struct {
+	Title string `json:"title"`
+	Content string `json:"content"`
+	Language string `json:"language"`
+	Files []snippetFileForm `json:"files"`
+	Expires int `json:"expires"`
+	Tags []string `json:"tags"`
+	Private bool `json:"private"`
+	PublishAnyway bool `json:"publish_anyway"`
+	validator.Validator `json:"-"`
+}
+
+type apiSnippet struct {
+	ID int `json:"id"`
+	Title string `json:"title"`
+	Language string `json:"language"`
+	URL string `json:"url"`
+	Private bool `json:"private"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// snippetURL is the public address of the snippet page, built from -base-url
+// rather than the request so clients can't choose the host.
+func (a *application) snippetURL(id int) string {
+	return fmt.Sprintf("%s/snippet/view/%d", a.baseURL, id)
+}
+
+func (a *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetCreateInput
+	if err := a.readJSON(w, r, &input); err != nil {
+		a.errorJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Expires == 0 {
+		input.Expires = 365
+	}
+	if input.Language == "" {
+		input.Language = "text"
+	}
+
+	input.CheckField(validator.NotBlank(input.Title), "title", "this field cannot be blank")
+	input.CheckField(validator.MaxChars(input.Title, 100), "title", "this field cannot be more than 100 characters long")
+
+	// a single file can be sent as content and language, several as files
+	var files []*models.SnippetFile
+	if len(input.Files) == 0 {
+		input.CheckField(validator.NotBlank(input.Content), "content", "content cannot be blank")
+		input.CheckField(validator.PermittedValue(input.Language, snippetLanguages...), "language", "unsupported language")
+		files = []*models.SnippetFile{{Language: input.Language, Content: input.Content}}
+	} else {
+		input.CheckField(input.Content == "", "content", "send either content or files")
+		for i := range input.Files {
+			if input.Files[i].Language == "" {
+				input.Files[i].Language = "text"
+			}
+		}
+		files = checkFiles(&input.Validator, input.Files)
+	}
+
+	input.CheckField(validator.PermittedValue(input.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	tags := parseTags(strings.Join(input.Tags, ","))
+	checkTags(&input.Validator, tags)
+	if message := a.scanFiles(files); message != "" && !(input.Private && input.PublishAnyway) {
+		input.AddFieldError("content", message)
+	}
+
+	if !input.Valid() {
+		a.errorJSON(w, http.StatusUnprocessableEntity, input.FieldErrors)
+		return
+	}
+
+	userID := a.apiUserID(r)
+	user, err := a.users.Get(userID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	if !user.EmailVerified {
+		a.errorJSON(w, http.StatusForbidden, "verify your email address before creating snippets")
+		return
+	}
+
+	id, err := a.snippets.Insert(userID, input.Title, files, tags, input.Expires, input.Private)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err = a.logAudit(r, userID, models.AuditSnippetCreate, fmt.Sprintf("created snippet %d through the API", id)); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/snippet/view/%d", id))
+	a.writeJSON(w, http.StatusCreated, map[string]any{"snippet": apiSnippet{
+		ID: snippet.ID,
+		Title: snippet.Title,
+		Language: snippet.Language,
+		URL: a.snippetURL(snippet.ID),
+		Private: snippet.Private,
+		Created: snippet.Created,
+		Expires: snippet.Expires,
+	}})
+}
+
+func (a *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	snippets, err := a.snippets.ByUser(a.apiUserID(r))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	list := []apiSnippet{}
+	for _, s := range snippets {
+		list = append(list, apiSnippet{
+			ID: s.ID,
+			Title: s.Title,
+			Language: s.Language,
+			URL: a.snippetURL(s.ID),
+			Private: s.Private,
+			Created: s.Created,
+			Expires: s.Expires,
+		})
+	}
+
+	a.writeJSON(w, http.StatusOK, map[string]any{"snippets": list})
+}
+
+// apiSnippetRaw serves the raw content like /snippet/raw, but also the private
+// and hidden snippets of the token's user.
+func (a *application) apiSnippetRaw(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		a.errorJSON(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if (snippet.Private || snippet.Hidden) && snippet.UserID != a.apiUserID(r) {
+		a.errorJSON(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	content, status, err := a.rawContent(snippet, params.ByName("file"), r.URL.Query().Get("lines"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	switch status {
+	case http.StatusNotFound:
+		a.errorJSON(w, status, "file not found")
+		return
+	case http.StatusBadRequest:
+		a.errorJSON(w, status, "invalid lines range")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(content))
+}
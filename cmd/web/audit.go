@@ -0,0 +1,48 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"net/http"
+	"strconv"
+)
+
+func (a *application) accountActivity(w http.ResponseWriter, r *http.Request) {
+	page, offset := pageParams(r)
+	entries, err := a.audit.ForUser(a.sessionManager.GetInt(r.Context(), "id"), pageSize+1, offset)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(entries))
+	data.AuditEntries = entries[:min(len(entries), pageSize)]
+	a.render(w, http.StatusOK, "activity.tmpl", data)
+}
+
+// adminAudit lists the whole audit log, or with ?user= only the entries
+// about one user.
+func (a *application) adminAudit(w http.ResponseWriter, r *http.Request) {
+	page, offset := pageParams(r)
+	userID, _ := strconv.Atoi(r.URL.Query().Get("user"))
+
+	var (
+		entries []*models.AuditEntry
+		err error
+	)
+	if userID > 0 {
+		entries, err = a.audit.ForUser(userID, pageSize+1, offset)
+	} else {
+		entries, err = a.audit.All(pageSize+1, offset)
+	}
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(entries))
+	data.AuditEntries = entries[:min(len(entries), pageSize)]
+	data.FilterUserID = max(userID, 0)
+	a.render(w, http.StatusOK, "admin_audit.tmpl", data)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type collectionForm struct {
+	Name string `form:"name"`
+	Private bool `form:"private"`
+	validator.Validator `form:"-"`
+}
+
+type collectionSnippetForm struct {
+	Collection int `form:"collection"`
+	Snippet int `form:"snippet"`
+	Direction string `form:"direction"`
+}
+
+// ownCollection loads the collection if it belongs to the logged in user.
+// Anyone else gets a 404, so they can't tell it exists.
+func (a *application) ownCollection(w http.ResponseWriter, r *http.Request, id int) (*models.Collection, bool) {
+	collection, err := a.collections.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if collection.UserID != a.sessionManager.GetInt(r.Context(), "id") {
+		a.notFound(w)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+func (a *application) checkCollectionForm(form *collectionForm) {
+	form.Name = strings.TrimSpace(form.Name)
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+}
+
+func (a *application) collectionList(w http.ResponseWriter, r *http.Request) {
+	collections, err := a.collections.ForUser(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Collections = collections
+	data.Form = collectionForm{}
+	a.render(w, http.StatusOK, "collections.tmpl", data)
+}
+
+func (a *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+
+	a.checkCollectionForm(&form)
+	if !form.Valid() {
+		collections, err := a.collections.ForUser(userID)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+
+		data := a.newTemplateData(r)
+		data.Collections = collections
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "collections.tmpl", data)
+		return
+	}
+
+	id, err := a.collections.Insert(userID, form.Name, form.Private)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The collection has been created, add snippets to it from their pages")
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", id), http.StatusSeeOther)
+}
+
+// collectionView shows every snippet of the collection in order. Snippets the
+// visitor isn't allowed to see are left out.
+func (a *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	collection, err := a.collections.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	owner := a.IsAuthenticated(r) && collection.UserID == a.sessionManager.GetInt(r.Context(), "id")
+	if collection.Private && !owner {
+		a.notFound(w)
+		return
+	}
+
+	snippets, err := a.collections.Snippets(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	visible := []*models.Snippet{}
+	files := map[int][]*models.SnippetFile{}
+	for _, s := range snippets {
+		if !a.canSee(r, s) {
+			continue
+		}
+
+		files[s.ID], err = a.snippets.Files(s)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		visible = append(visible, s)
+	}
+
+	data := a.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = visible
+	data.SnippetFiles = files
+	a.render(w, http.StatusOK, "collection.tmpl", data)
+}
+
+func (a *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	id, _ := idParam(r)
+	collection, ok := a.ownCollection(w, r, id)
+	if !ok {
+		return
+	}
+
+	a.renderCollectionEdit(w, r, http.StatusOK, collection, collectionForm{Name: collection.Name, Private: collection.Private})
+}
+
+func (a *application) renderCollectionEdit(w http.ResponseWriter, r *http.Request, status int, collection *models.Collection, form collectionForm) {
+	snippets, err := a.collections.Snippets(collection.ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// the owner has to be able to remove snippets that were made private or
+	// hidden since they were added, without learning what they are
+	for i, s := range snippets {
+		if !a.canSee(r, s) {
+			snippets[i] = &models.Snippet{ID: s.ID, Title: "Unavailable snippet"}
+		}
+	}
+
+	data := a.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = snippets
+	data.Form = form
+	a.render(w, status, "collection_edit.tmpl", data)
+}
+
+func (a *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	id, _ := idParam(r)
+	collection, ok := a.ownCollection(w, r, id)
+	if !ok {
+		return
+	}
+
+	var form collectionForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	a.checkCollectionForm(&form)
+	if !form.Valid() {
+		a.renderCollectionEdit(w, r, http.StatusUnprocessableEntity, collection, form)
+		return
+	}
+
+	if err := a.collections.Update(collection.ID, form.Name, form.Private); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The collection has been saved")
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+func (a *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, _ := idParam(r)
+	collection, ok := a.ownCollection(w, r, id)
+	if !ok {
+		return
+	}
+
+	if err := a.collections.Delete(collection.ID); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The collection has been deleted, its snippets are still there")
+	http.Redirect(w, r, "/collections", http.StatusSeeOther)
+}
+
+// snippetCollectPost adds the snippet to one of the user's collections, picked
+// on the snippet page.
+func (a *application) snippetCollectPost(w http.ResponseWriter, r *http.Request) {
+	snippetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	var form collectionSnippetForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, err := a.snippets.Get(snippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+
+	collection, ok := a.ownCollection(w, r, form.Collection)
+	if !ok {
+		return
+	}
+
+	if err := a.collections.AddSnippet(collection.ID, snippet.ID); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("The snippet has been added to %s", collection.Name))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (a *application) collectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	id, _ := idParam(r)
+	collection, ok := a.ownCollection(w, r, id)
+	if !ok {
+		return
+	}
+
+	var form collectionSnippetForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := a.collections.RemoveSnippet(collection.ID, form.Snippet); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
+
+func (a *application) collectionMovePost(w http.ResponseWriter, r *http.Request) {
+	id, _ := idParam(r)
+	collection, ok := a.ownCollection(w, r, id)
+	if !ok {
+		return
+	}
+
+	var form collectionSnippetForm
+	if err := a.decodePostForm(r, &form); err != nil || !validator.PermittedValue(form.Direction, "up", "down") {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := a.collections.MoveSnippet(collection.ID, form.Snippet, form.Direction == "up")
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.clientError(w, http.StatusBadRequest)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/edit/%d", collection.ID), http.StatusSeeOther)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type commentForm struct {
+	Body string `form:"body"`
+	Parent int `form:"parent"`
+	File string `form:"file"`
+	Line int `form:"line"`
+	validator.Validator `form:"-"`
+}
+
+func (form *commentForm) checkBody() {
+	form.Body = strings.TrimSpace(form.Body)
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, 2000), "body", "This field cannot be more than 2000 characters long")
+}
+
+// commentURL links to the comment on its snippet page.
+func commentURL(c *models.Comment) string {
+	return fmt.Sprintf("/snippet/view/%d#comment-%d", c.SnippetID, c.ID)
+}
+
+func (a *application) snippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(snippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+	if snippet.Hidden {
+		a.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	var form commentForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.checkBody()
+
+	if form.Parent != 0 {
+		parent, err := a.comments.Get(form.Parent)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			a.serverError(w, err)
+			return
+		}
+		if err != nil || parent.SnippetID != snippet.ID {
+			a.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// a comment can point at a line of one of the files
+	if form.Line != 0 {
+		files, err := a.snippets.Files(snippet)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+
+		i := slices.IndexFunc(files, func(f *models.SnippetFile) bool { return f.Name == form.File })
+		if i < 0 {
+			a.clientError(w, http.StatusBadRequest)
+			return
+		}
+		form.CheckField(form.Line > 0 && form.Line <= lineCount(files[i].Content), "line", "There is no such line")
+	} else {
+		form.File = ""
+	}
+
+	if !form.Valid() {
+		a.renderSnippet(w, r, http.StatusUnprocessableEntity, snippet, snippetReportForm{}, form)
+		return
+	}
+
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+	id, err := a.comments.Insert(snippet.ID, userID, form.Parent, form.File, form.Line, form.Body)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, commentURL(&models.Comment{ID: id, SnippetID: snippet.ID}), http.StatusSeeOther)
+}
+
+// ownComment loads the comment named in the URL if the logged in user wrote
+// it and it is still up.
+func (a *application) ownComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return nil, false
+	}
+
+	comment, err := a.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if comment.UserID != a.sessionManager.GetInt(r.Context(), "id") || comment.Removed != "" {
+		a.notFound(w)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+func (a *application) commentEdit(w http.ResponseWriter, r *http.Request) {
+	comment, ok := a.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Comment = comment
+	data.Form = commentForm{Body: comment.Body}
+	a.render(w, http.StatusOK, "comment_edit.tmpl", data)
+}
+
+func (a *application) commentEditPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := a.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.checkBody()
+	if !form.Valid() {
+		data := a.newTemplateData(r)
+		data.Comment = comment
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "comment_edit.tmpl", data)
+		return
+	}
+
+	if err := a.comments.Update(comment.ID, form.Body); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, commentURL(comment), http.StatusSeeOther)
+}
+
+func (a *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := a.ownComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := a.comments.Remove(comment.ID, models.CommentRemovedByAuthor); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Your comment has been deleted")
+	http.Redirect(w, r, commentURL(comment), http.StatusSeeOther)
+}
+
+// commentRemovePost lets moderators take down any comment.
+func (a *application) commentRemovePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	comment, err := a.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if err := a.comments.Remove(comment.ID, models.CommentRemovedByModerator); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	moderatorID := a.sessionManager.GetInt(r.Context(), "id")
+	detail := fmt.Sprintf("comment %d by user %d on snippet %d", comment.ID, comment.UserID, comment.SnippetID)
+	if err := a.logAudit(r, moderatorID, models.AuditAdminCommentRemove, detail); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The comment has been removed")
+	http.Redirect(w, r, commentURL(comment), http.StatusSeeOther)
+}
@@ -3,3 +3,5 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+const apiUserIDContextKey = contextKey("apiUserID")
+const userRoleContextKey = contextKey("userRole")
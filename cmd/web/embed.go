@@ -0,0 +1,137 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// the size of the iframe handed out by oEmbed, unless the consumer asks for
+// something smaller
+const (
+	embedWidth = 640
+	embedHeight = 400
+)
+
+// embedHTML is the iframe that shows the snippet on another site.
+func embedHTML(baseURL string, snippet *models.Snippet, width, height int) string {
+	return fmt.Sprintf(`<iframe src="%s/snippet/embed/%d" width="%d" height="%d" title="%s" loading="lazy"></iframe>`,
+		baseURL, snippet.ID, width, height, html.EscapeString(snippet.Title))
+}
+
+type oEmbedResponse struct {
+	Version string `json:"version"`
+	Type string `json:"type"`
+	Title string `json:"title"`
+	AuthorName string `json:"author_name,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL string `json:"provider_url"`
+	HTML string `json:"html"`
+	Width int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (a *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		a.notFound(w)
+		return
+	}
+
+	snippet, ok := a.publicSnippet(w, id)
+	if !ok {
+		return
+	}
+
+	files, err := a.snippets.Files(snippet)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// embeds are served without a session, so there is no newTemplateData
+	data := &templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet: snippet,
+		Files: files,
+		BaseURL: a.baseURL,
+	}
+	a.renderLayout(w, http.StatusOK, "embed.tmpl", "embed", data)
+}
+
+// oEmbed answers https://oembed.com requests for snippet pages with an iframe
+// of /snippet/embed/:id.
+func (a *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "json" {
+		a.errorJSON(w, http.StatusNotImplemented, "only the json format is supported")
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(query.Get("url"), a.baseURL+"/snippet/view/"))
+	if err != nil || id < 1 {
+		a.errorJSON(w, http.StatusNotFound, "not a snippet of this site")
+		return
+	}
+
+	width, height := embedWidth, embedHeight
+	for _, limit := range []struct {
+		name string
+		size *int
+	}{{"maxwidth", &width}, {"maxheight", &height}} {
+		value := query.Get(limit.name)
+		if value == "" {
+			continue
+		}
+		max, err := strconv.Atoi(value)
+		if err != nil || max < 1 {
+			a.errorJSON(w, http.StatusBadRequest, limit.name+" must be a positive number")
+			return
+		}
+		*limit.size = min(*limit.size, max)
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.errorJSON(w, http.StatusNotFound, "snippet not found")
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if snippet.Hidden || snippet.Private {
+		a.errorJSON(w, http.StatusNotFound, "snippet not found")
+		return
+	}
+
+	var author string
+	user, err := a.users.Get(snippet.UserID)
+	if err == nil {
+		author = user.Name
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		a.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	a.writeJSON(w, http.StatusOK, oEmbedResponse{
+		Version: "1.0",
+		Type: "rich",
+		Title: snippet.Title,
+		AuthorName: author,
+		ProviderName: "Snippetbox",
+		ProviderURL: a.baseURL,
+		HTML: embedHTML(a.baseURL, snippet, width, height),
+		Width: width,
+		Height: height,
+	})
+}
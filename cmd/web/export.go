@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/zip"
+	"caniteySnippetBox/internal/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// snippetExtensions maps snippet languages to the extension of the file they
+// are exported as.
+var snippetExtensions = map[string]string{
+	"text": "txt",
+	"go": "go",
+	"python": "py",
+	"javascript": "js",
+	"shell": "sh",
+	"sql": "sql",
+	"json": "json",
+	"yaml": "yaml",
+	"html": "html",
+	"css": "css",
+	"markdown": "md",
+}
+
+type exportUser struct {
+	ID int `json:"id"`
+	Name string `json:"name"`
+	Email string `json:"email"`
+	EmailVerified bool `json:"email_verified"`
+	TOTPEnabled bool `json:"totp_enabled"`
+	Created time.Time `json:"created"`
+}
+
+type exportSnippet struct {
+	ID int `json:"id"`
+	Title string `json:"title"`
+	Language string `json:"language"`
+	Private bool `json:"private"`
+	Tags []string `json:"tags"`
+	File string `json:"file"`
+	Files []string `json:"files"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+type exportManifest struct {
+	Exported time.Time `json:"exported"`
+	User exportUser `json:"user"`
+	Snippets []exportSnippet `json:"snippets"`
+}
+
+// accountExport streams a ZIP with every snippet of the user as its own file,
+// or a directory of files for snippets with several, and a manifest.json
+// describing the account and the snippets. The snippets
+// are written as they are read from the database, only their metadata is
+// kept around for the manifest, which comes last.
+func (a *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	user, err := a.users.Get(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// big accounts can take longer than the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(5 * time.Minute))
+
+	manifest := exportManifest{
+		Exported: time.Now().UTC(),
+		User: exportUser{
+			ID: user.ID,
+			Name: user.Name,
+			Email: user.Email,
+			EmailVerified: user.EmailVerified,
+			TOTPEnabled: user.TOTPEnabled,
+			Created: user.Created,
+		},
+		Snippets: []exportSnippet{},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="snippetbox-export-%s.zip"`, manifest.Exported.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+
+	zw := zip.NewWriter(w)
+
+	err = a.snippets.EachByUser(user.ID, func(s *models.Snippet) error {
+		files, err := a.snippets.Files(s)
+		if err != nil {
+			return err
+		}
+
+		var names []string
+		for _, file := range files {
+			var name string
+			if len(files) == 1 {
+				ext, ok := snippetExtensions[file.Language]
+				if !ok {
+					ext = "txt"
+				}
+				name = fmt.Sprintf("snippets/%d.%s", s.ID, ext)
+			} else {
+				name = fmt.Sprintf("snippets/%d/%s", s.ID, file.Name)
+			}
+
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: s.Created})
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write([]byte(file.Content)); err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+
+		tags, err := a.tags.ForSnippet(s.ID)
+		if err != nil {
+			return err
+		}
+
+		manifest.Snippets = append(manifest.Snippets, exportSnippet{
+			ID: s.ID,
+			Title: s.Title,
+			Language: s.Language,
+			Private: s.Private,
+			Tags: tags,
+			File: names[0],
+			Files: names,
+			Created: s.Created,
+			Expires: s.Expires,
+		})
+		return nil
+	})
+	if err == nil {
+		var f io.Writer
+		f, err = zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: manifest.Exported})
+		if err == nil {
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "\t")
+			err = enc.Encode(manifest)
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+
+	// the headers are gone by now, so all that can be done is to stop and
+	// leave the client with a truncated archive
+	if err != nil {
+		a.errorLog.Printf("export for user %d: %s", user.ID, err)
+	}
+}
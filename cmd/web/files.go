@@ -0,0 +1,149 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/secrets"
+	"caniteySnippetBox/internal/validator"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxFiles is how many files a snippet can have.
+const maxFiles = 10
+
+type snippetFileForm struct {
+	Name string `form:"name" json:"name"`
+	Language string `form:"language" json:"language"`
+	Content string `form:"content" json:"content"`
+}
+
+// compactFiles drops the files that were left empty on the form, keeping one
+// so there is always something to fill in.
+func compactFiles(files []snippetFileForm) []snippetFileForm {
+	kept := []snippetFileForm{}
+	for _, f := range files {
+		if strings.TrimSpace(f.Name) != "" || strings.TrimSpace(f.Content) != "" {
+			kept = append(kept, f)
+		}
+	}
+
+	if len(kept) == 0 {
+		kept = append(kept, snippetFileForm{Language: "text"})
+	}
+	return kept
+}
+
+// checkFiles validates the files, keyed as files.<index>.<field>, and returns
+// them ready to be stored. Names are optional for a single file.
+func checkFiles(v *validator.Validator, files []snippetFileForm) []*models.SnippetFile {
+	v.CheckField(validator.MaxItems(files, maxFiles), "files", fmt.Sprintf("A snippet can have at most %d files", maxFiles))
+
+	seen := map[string]bool{}
+	checked := make([]*models.SnippetFile, len(files))
+	for i, f := range files {
+		key := "files." + strconv.Itoa(i) + "."
+		name := strings.TrimSpace(f.Name)
+
+		if len(files) > 1 {
+			v.CheckField(validator.NotBlank(name), key+"name", "Every file needs a name when there are several")
+		}
+		if name != "" {
+			v.CheckField(validator.MaxChars(name, 100), key+"name", "This field cannot be more than 100 characters long")
+			v.CheckField(validator.Matches(name, validator.FilenameRX), key+"name", "Only letters, digits and . _ - are allowed")
+			v.CheckField(!seen[name], key+"name", "Another file already has this name")
+			seen[name] = true
+		}
+		v.CheckField(validator.NotBlank(f.Content), key+"content", "content cannot be blank")
+		v.CheckField(validator.PermittedValue(f.Language, snippetLanguages...), key+"language", "unsupported language")
+
+		checked[i] = &models.SnippetFile{Name: name, Language: f.Language, Content: f.Content}
+	}
+
+	return checked
+}
+
+// scanFiles runs the secret scanner over every file, returning a message
+// describing what it found or "" if the files look clean.
+func (a *application) scanFiles(files []*models.SnippetFile) string {
+	var found []string
+	for _, f := range files {
+		findings := a.scanner.Scan(f.Content)
+		if len(findings) == 0 {
+			continue
+		}
+
+		if len(files) > 1 {
+			found = append(found, f.Name+": "+describeFindings(findings))
+		} else {
+			found = append(found, describeFindings(findings))
+		}
+	}
+
+	if len(found) == 0 {
+		return ""
+	}
+
+	return "This looks like it contains secrets: " + strings.Join(found, "; ") +
+		". Remove them, or make the snippet private and confirm you want to publish it anyway."
+}
+
+// describeFindings lists what the scanner found, grouping the lines by rule.
+func describeFindings(findings []secrets.Finding) string {
+	var rules []string
+	lines := map[string][]string{}
+	for _, f := range findings {
+		if _, ok := lines[f.Rule]; !ok {
+			rules = append(rules, f.Rule)
+		}
+		lines[f.Rule] = append(lines[f.Rule], strconv.Itoa(f.Line))
+	}
+
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		word := "line"
+		if len(lines[rule]) > 1 {
+			word = "lines"
+		}
+		parts[i] = fmt.Sprintf("%s on %s %s", rule, word, strings.Join(lines[rule], ", "))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// lineCount counts the lines of the content, a final newline doesn't start
+// another one.
+func lineCount(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// lineRange parses a range of lines such as "10-20", or "10" for a single one.
+func lineRange(s string) (int, int, bool) {
+	first, last, found := strings.Cut(s, "-")
+	from, err := strconv.Atoi(first)
+	if err != nil || from < 1 {
+		return 0, 0, false
+	}
+	if !found {
+		return from, from, true
+	}
+
+	to, err := strconv.Atoi(last)
+	if err != nil || to < from {
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+// sliceLines returns lines from to to of the content, counted from 1. A range
+// running past the end stops at the last line.
+func sliceLines(content string, from, to int) string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if from > len(lines) {
+		return ""
+	}
+	return strings.Join(lines[from-1:min(to, len(lines))], "")
+}
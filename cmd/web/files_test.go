@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+	"caniteySnippetBox/internal/validator"
+)
+
+func TestCheckFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		files []snippetFileForm
+		wantFiles int
+		wantError string
+	}{
+		{
+			name: "Single file without a name",
+			files: []snippetFileForm{{Language: "go", Content: "package main"}},
+			wantFiles: 1,
+		},
+		{
+			name: "Empty files dropped",
+			files: []snippetFileForm{{Name: "main.go", Language: "go", Content: "package main"}, {Language: "text"}},
+			wantFiles: 1,
+		},
+		{
+			name: "Nothing filled in",
+			files: []snippetFileForm{{Language: "text"}, {Language: "text"}},
+			wantFiles: 1,
+			wantError: "files.0.content",
+		},
+		{
+			name: "Several files need names",
+			files: []snippetFileForm{{Name: "main.go", Language: "go", Content: "package main"}, {Language: "text", Content: "module x"}},
+			wantFiles: 2,
+			wantError: "files.1.name",
+		},
+		{
+			name: "Duplicate names",
+			files: []snippetFileForm{{Name: "a.go", Language: "go", Content: "x"}, {Name: "a.go", Language: "go", Content: "y"}},
+			wantFiles: 2,
+			wantError: "files.1.name",
+		},
+		{
+			name: "Path in name",
+			files: []snippetFileForm{{Name: "../a.go", Language: "go", Content: "x"}},
+			wantFiles: 1,
+			wantError: "files.0.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v validator.Validator
+			files := checkFiles(&v, compactFiles(tt.files))
+			assert.Equal(t, len(files), tt.wantFiles)
+
+			if tt.wantError == "" {
+				assert.Equal(t, v.Valid(), true)
+			} else {
+				_, ok := v.FieldErrors[tt.wantError]
+				assert.Equal(t, ok, true)
+			}
+		})
+	}
+}
+
+func TestSliceLines(t *testing.T) {
+	content := "one\ntwo\nthree\nfour\n"
+
+	tests := []struct {
+		name string
+		lines string
+		want string
+		wantOK bool
+	}{
+		{
+			name: "Single line",
+			lines: "2",
+			want: "two\n",
+			wantOK: true,
+		},
+		{
+			name: "Range",
+			lines: "2-3",
+			want: "two\nthree\n",
+			wantOK: true,
+		},
+		{
+			name: "Past the end",
+			lines: "3-10",
+			want: "three\nfour\n",
+			wantOK: true,
+		},
+		{
+			name: "Backwards",
+			lines: "3-2",
+		},
+		{
+			name: "Zero",
+			lines: "0-2",
+		},
+		{
+			name: "Not a number",
+			lines: "L2-L3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, ok := lineRange(tt.lines)
+			assert.Equal(t, ok, tt.wantOK)
+			if ok {
+				assert.Equal(t, sliceLines(content, from, to), tt.want)
+			}
+		})
+	}
+}
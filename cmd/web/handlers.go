@@ -2,20 +2,31 @@ package main
 
 import (
 	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/signer"
 	"caniteySnippetBox/internal/validator"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 
+var snippetLanguages = []string{"text", "go", "python", "javascript", "shell", "sql", "json", "yaml", "html", "css", "markdown"}
+
 type snippetCreateForm struct {
 	Title string `form:"title"`
-	Content string `form:"content"`
+	Files []snippetFileForm `form:"files"`
+	AddFile bool `form:"addFile"`
+	Preview bool `form:"preview"`
 	Expires int `form:"expires"`
+	Tags string `form:"tags"`
+	Private bool `form:"private"`
+	PublishAnyway bool `form:"publishAnyway"`
+	SecretsFound bool `form:"-"`
 	validator.Validator `form:"-"`
 }
 
@@ -39,6 +50,18 @@ type accountPasswordUpdateForm struct {
 	validator.Validator `form:"-"`
 }
 
+type userPasswordForgotForm struct {
+	Email string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type userPasswordResetForm struct {
+	Token string `form:"-"`
+	NewPassword string `form:"newPassword"`
+	NewPasswordConfirmation string `form:"newPasswordConfirmation"`
+	validator.Validator `form:"-"`
+}
+
 func (a *application)ping(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
@@ -50,7 +73,16 @@ func (a *application)about (w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *application)home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := a.snippets.Latest()
+	sort := r.URL.Query().Get("sort")
+
+	var snippets []*models.Snippet
+	var err error
+	if sort == "stars" {
+		snippets, err = a.snippets.MostStarred()
+	} else {
+		sort = "latest"
+		snippets, err = a.snippets.Latest()
+	}
 	if err != nil {
 		a.serverError(w, err)
 		return
@@ -58,6 +90,7 @@ func (a *application)home(w http.ResponseWriter, r *http.Request) {
 
 	data := a.newTemplateData(r)
 	data.Snippets = snippets
+	data.Sort = sort
 	a.render(w, http.StatusOK, "home.tmpl", data)
 }
 
@@ -79,15 +112,153 @@ func (a *application)snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+
+	a.countView(r, snippet)
+	a.renderSnippet(w, r, http.StatusOK, snippet, snippetReportForm{}, commentForm{})
+}
+
+// renderSnippet renders the snippet page with everything around the snippet,
+// and the report and comment forms as given so they can show their errors.
+func (a *application) renderSnippet(w http.ResponseWriter, r *http.Request, status int, snippet *models.Snippet, report snippetReportForm, comment commentForm) {
+	files, err := a.snippets.Files(snippet)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	tags, err := a.tags.ForSnippet(snippet.ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	forks, err := a.snippets.Forks(snippet.ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	comments, err := a.comments.ForSnippet(snippet.ID)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	var collections []*models.Collection
+	var starred bool
+	if a.IsAuthenticated(r) {
+		userID := a.sessionManager.GetInt(r.Context(), "id")
+		collections, err = a.collections.ForUser(userID)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		starred, err = a.stars.Has(userID, snippet.ID)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
 
 	data := a.newTemplateData(r)
 	data.Snippet = snippet
-	a.render(w, http.StatusOK, "view.tmpl", data)
+	data.Files = files
+	data.Tags = tags
+	data.Forks = forks
+	data.Starred = starred
+	data.Collections = collections
+	data.Comments = comments
+	data.Form = report
+	data.CommentForm = comment
+	a.render(w, status, "view.tmpl", data)
+}
+
+// publicSnippet fetches a snippet for the pages served without a session, so
+// hidden and private ones are gone for everybody. It has written the response
+// when it returns false.
+func (a *application) publicSnippet(w http.ResponseWriter, id int) (*models.Snippet, bool) {
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if snippet.Hidden || snippet.Private {
+		a.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+func (a *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		a.notFound(w)
+		return
+	}
+
+	snippet, ok := a.publicSnippet(w, id)
+	if !ok {
+		return
+	}
+
+	content, status, err := a.rawContent(snippet, params.ByName("file"), r.URL.Query().Get("lines"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	if status != http.StatusOK {
+		a.clientError(w, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(content))
+}
+
+// rawContent picks the named file of the snippet, the first one if name is
+// empty, and cuts it down to the lines range if there is one. Files and
+// ranges that don't exist give a 404 or 400 status rather than an error.
+func (a *application) rawContent(snippet *models.Snippet, name, lines string) (string, int, error) {
+	content := snippet.Content
+	if name != "" && name != snippet.Filename {
+		files, err := a.snippets.Files(snippet)
+		if err != nil {
+			return "", 0, err
+		}
+
+		i := slices.IndexFunc(files, func(f *models.SnippetFile) bool { return f.Name == name })
+		if i < 0 {
+			return "", http.StatusNotFound, nil
+		}
+		content = files[i].Content
+	}
+
+	if lines != "" {
+		from, to, ok := lineRange(lines)
+		if !ok || from > lineCount(content) {
+			return "", http.StatusBadRequest, nil
+		}
+		content = sliceLines(content, from, to)
+	}
+
+	return content, http.StatusOK, nil
 }
 
 func (a *application) snippetCreateForm(w http.ResponseWriter, r *http.Request) {
 	data := a.newTemplateData(r)
 	data.Form = snippetCreateForm{
+		Files: []snippetFileForm{{Language: "text"}},
 		Expires: 365,
 	}
 	a.render(w, http.StatusOK, "create.tmpl", data)
@@ -107,10 +278,33 @@ func (a *application)snippetCreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	form.Files = compactFiles(form.Files)
+
+	// the "Add another file" and "Preview" buttons submit the form to get one
+	// more empty file or to see the Markdown files rendered, nothing is saved
+	if form.AddFile || form.Preview {
+		if form.AddFile && len(form.Files) < maxFiles {
+			form.Files = append(form.Files, snippetFileForm{Language: "text"})
+		}
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusOK, "create.tmpl", data)
+		return
+	}
+
 	form.CheckField(validator.NotBlank(form.Title), "title", "this field cannot be blank")
 	form.CheckField(validator.MaxChars(form.Title, 100), "title", "this field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "content cannot be blank")
+	files := checkFiles(&form.Validator, form.Files)
 	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	tags := parseTags(form.Tags)
+	checkTags(&form.Validator, tags)
+
+	// secrets in a public snippet are as good as leaked, so only a private
+	// snippet can be published with them
+	if message := a.scanFiles(files); message != "" && !(form.Private && form.PublishAnyway) {
+		form.SecretsFound = true
+		form.AddNonFieldError(message)
+	}
 
 	if !form.Valid() {
 		data := a.	newTemplateData(r)
@@ -119,18 +313,110 @@ func (a *application)snippetCreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := a.snippets.Insert(form.Title, form.Content, form.Expires)
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+	id, err := a.snippets.Insert(userID, form.Title, files, tags, form.Expires, form.Private)
 	if err != nil {
 		a.serverError(w, err)
 		return
 	}
 
+	if err = a.logAudit(r, userID, models.AuditSnippetCreate, fmt.Sprintf("created snippet %d", id)); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
 	a.sessionManager.Put(r.Context(), "flash", "snippet created successfully")
 
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 
 }
 
+// snippetForkPost copies someone's snippet, or one of the user's own, into a
+// new snippet of the user.
+func (a *application) snippetForkPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+
+	// a fork of a hidden snippet would put it back on the site
+	if snippet.Hidden {
+		a.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+	forkID, err := a.snippets.Fork(id, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if err = a.logAudit(r, userID, models.AuditSnippetFork, fmt.Sprintf("forked snippet %d from %d", forkID, id)); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("This is your fork of snippet #%d", id))
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", forkID), http.StatusSeeOther)
+}
+
+func (a *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+	if snippet.UserID != userID {
+		a.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	if err = a.snippets.Delete(id); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err = a.logAudit(r, userID, models.AuditSnippetDelete, fmt.Sprintf("deleted snippet %d", id)); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The snippet has been deleted")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 
 func (a *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := a.newTemplateData(r)
@@ -159,20 +445,30 @@ func (a *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.users.Insert(form.Name, form.Email, form.Password)
+	id, err := a.users.Insert(form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "email already in use")
 			data := a.newTemplateData(r)
 			data.Form = form
-			a.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+			a.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		} else {
 			a.serverError(w, err)
 		}
 		return
 	}
 
-	a.sessionManager.Put(r.Context(), "flash", "you signed up successfully")
+	if err = a.logAudit(r, id, models.AuditSignup, "signed up as "+form.Email); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err = a.sendVerificationEmail(id, form.Name, form.Email); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "you signed up successfully, check your inbox to verify your email")
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
@@ -200,9 +496,32 @@ func (a *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := a.clientIP(r)
+	account := strings.ToLower(form.Email)
+	if wait := max(a.loginIPThrottle.wait(ip), a.loginAccountThrottle.wait(account)); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.AddNonFieldError("Too many failed login attempts, please try again later")
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusTooManyRequests, "login.tmpl", data)
+		return
+	}
+
 	id, err := a.users.Authenticate(form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
+			var userID int
+			if user, err := a.users.GetByEmail(form.Email); err == nil {
+				userID = user.ID
+			} else if !errors.Is(err, models.ErrNoRecord) {
+				a.serverError(w, err)
+				return
+			}
+
+			if err := a.recordLoginFailure(r, ip, account, userID, "wrong password for "+account); err != nil {
+				a.serverError(w, err)
+				return
+			}
 			form.AddNonFieldError("Email or password is incorrect")
 			data := a.newTemplateData(r)
 			data.Form = form
@@ -213,16 +532,34 @@ func (a *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = a.sessionManager.RenewToken(r.Context())
+	a.loginAccountThrottle.reset(account)
+
+	user, err := a.users.Get(id)
 	if err != nil {
 		a.serverError(w, err)
 		return
 	}
 
-	a.sessionManager.Put(r.Context(), "id", id)
-	a.sessionManager.Put(r.Context(), "isAuthenticated", true)
-	err = a.sessionManager.RenewToken(r.Context())
-	if err != nil {
+	if user.Disabled {
+		form.AddNonFieldError("This account has been disabled")
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusForbidden, "login.tmpl", data)
+		return
+	}
+
+	// with two-factor authentication the id only goes into the session once
+	// the second step has been passed as well
+	if user.TOTPEnabled {
+		if err = a.startTwoFactorLogin(r, id); err != nil {
+			a.serverError(w, err)
+			return
+		}
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if err = a.logIn(r, id, "password"); err != nil {
 		a.serverError(w, err)
 		return
 	}
@@ -231,13 +568,26 @@ func (a *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	err := a.sessionManager.RenewToken(r.Context())
+	err := a.logAudit(r, a.sessionManager.GetInt(r.Context(), "id"), models.AuditLogout, "logged out")
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	err = a.userSessions.Delete(a.sessionManager.Token(r.Context()))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	err = a.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		a.serverError(w, err)
 		return
 	}
 
 	a.sessionManager.Remove(r.Context(), "id")
+	a.sessionManager.Remove(r.Context(), "lastSeen")
 	a.sessionManager.Put(r.Context(), "flash", "You have been logged out successfully")
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -302,6 +652,187 @@ func (a *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.R
 		}
 	}
 
+	if err := a.logAudit(r, id, models.AuditPasswordChange, "changed password"); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// whoever else is logged in may be the reason the password was changed
+	if err := a.destroyUserSessions(id, a.sessionManager.Token(r.Context())); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
 	a.sessionManager.Put(r.Context(), "flash", "Password changed successfully")
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
+
+func (a *application) accountToken(w http.ResponseWriter, r *http.Request) {
+	data := a.newTemplateData(r)
+	a.render(w, http.StatusOK, "token.tmpl", data)
+}
+
+func (a *application) accountTokenPost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	token, err := a.tokens.New(id, apiTokenLifetime)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Token = token
+	a.render(w, http.StatusOK, "token.tmpl", data)
+}
+
+func (a *application) userVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	var payload verifyEmailPayload
+	err := a.signer.Verify(verifyEmailPurpose, params.ByName("token"), &payload)
+	if err == nil {
+		err = a.users.VerifyEmail(payload.UserID, payload.Email)
+	}
+	if err != nil {
+		if errors.Is(err, signer.ErrInvalidToken) || errors.Is(err, signer.ErrExpiredToken) || errors.Is(err, models.ErrNoRecord) {
+			a.sessionManager.Put(r.Context(), "flash", "This verification link is invalid, expired or was already used")
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Your email address has been verified")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (a *application) accountVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if user.EmailVerified {
+		a.sessionManager.Put(r.Context(), "flash", "Your email address is already verified")
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+		return
+	}
+
+	if err = a.sendVerificationEmail(user.ID, user.Name, user.Email); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "A new verification link has been sent to "+user.Email)
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (a *application) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	data := a.newTemplateData(r)
+	data.Form = userPasswordForgotForm{}
+	a.render(w, http.StatusOK, "forgot.tmpl", data)
+}
+
+func (a *application) userPasswordForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form userPasswordForgotForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be empty")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Not a valid email address")
+
+	if !form.Valid() {
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "forgot.tmpl", data)
+		return
+	}
+
+	// The response is the same whether or not the address belongs to an
+	// account, so the form can't be used to find out who has signed up.
+	user, err := a.users.GetByEmail(form.Email)
+	if err == nil {
+		err = a.sendPasswordResetEmail(user)
+	}
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "If an account uses that address, a link to reset the password is on its way")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+func (a *application) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	token := params.ByName("token")
+
+	valid, err := a.passwordResets.Valid(token)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	if !valid {
+		a.sessionManager.Put(r.Context(), "flash", "This reset link is invalid, expired or was already used")
+		http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		return
+	}
+
+	// keep the token out of the Referer header of any link followed from here
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	data := a.newTemplateData(r)
+	data.Form = userPasswordResetForm{Token: token}
+	a.render(w, http.StatusOK, "reset.tmpl", data)
+}
+
+func (a *application) userPasswordResetPost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	form := userPasswordResetForm{Token: params.ByName("token")}
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "newPassword", "field must not be empty")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "newPassword", "new password must not be less than 8 digits")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "newPasswordConfirmation", "field must not be empty")
+	form.CheckField(validator.Equal(form.NewPassword, form.NewPasswordConfirmation), "newPasswordConfirmation", "Passwords doesn't match")
+
+	if !form.Valid() {
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		return
+	}
+
+	userID, err := a.passwordResets.Reset(form.Token, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.sessionManager.Put(r.Context(), "flash", "This reset link is invalid, expired or was already used")
+			http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if err = a.logAudit(r, userID, models.AuditPasswordReset, "reset password with an emailed link"); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err = a.destroyUserSessions(userID, ""); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Your password has been reset, please log in")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	"caniteySnippetBox/internal/assert"
+
+	"github.com/alexedwards/scs/v2"
 )
 
 // func TestPing(t *testing.T) {
@@ -39,6 +41,7 @@ func TestPingNew(t *testing.T) {
 		app := &application{
 			errorLog: log.New(io.Discard, "", 0),
 			infoLog: log.New(io.Discard, "", 0),
+			sessionManager: scs.New(),
 		}
 
 		ts := httptest.NewTLSServer(app.routes())
@@ -2,22 +2,47 @@ package main
 
 import (
 	"bytes"
+	"caniteySnippetBox/internal/models"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
 func (a *application) newTemplateData(r *http.Request) *templateData {
+	var oidcName string
+	if a.oidc != nil {
+		oidcName = a.oidc.name
+	}
+
+	var userID int
+	if a.IsAuthenticated(r) {
+		userID = a.sessionManager.GetInt(r.Context(), "id")
+	}
+
 	return &templateData{
 		CurrentYear: time.Now().Year(),
 		Flash: a.sessionManager.PopString(r.Context(), "flash"),
 		IsAuthenticated: a.IsAuthenticated(r),
+		Role: a.userRole(r),
+		UserID: userID,
 		CSRFToken: nosurf.Token(r),
+		Languages: snippetLanguages,
+		ReportReasons: reportReasons,
+		OIDCName: oidcName,
+		BaseURL: a.baseURL,
 	}
 }
 
@@ -60,6 +85,12 @@ func (a *application) notFound(w http.ResponseWriter) {
 }
 
 func (a *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+	a.renderLayout(w, status, page, "base", data)
+}
+
+// renderLayout renders the page through another layout than base, like the
+// bare document of embedded snippets.
+func (a *application) renderLayout(w http.ResponseWriter, status int, page, layout string, data *templateData) {
 	ts, ok := a.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("The template %s does not exist", page)
@@ -70,7 +101,7 @@ func (a *application) render(w http.ResponseWriter, status int, page string, dat
 	buf := new(bytes.Buffer)
 
 
-	err := ts.ExecuteTemplate(buf, "base", data)
+	err := ts.ExecuteTemplate(buf, layout, data)
 	if err != nil {
 		a.serverError(w, err)
 	}
@@ -88,3 +119,274 @@ func (a *application) IsAuthenticated(r *http.Request) bool {
 
 	return isAuthenticated
 }
+
+// userRole returns the role of the logged in user, or "" for anonymous
+// requests.
+func (a *application) userRole(r *http.Request) string {
+	role, _ := r.Context().Value(userRoleContextKey).(string)
+	return role
+}
+
+// logAudit writes an event about the user to the audit log, along with where
+// the request came from.
+func (a *application) logAudit(r *http.Request, userID int, event, detail string) error {
+	return a.audit.Insert(userID, event, a.clientIP(r), r.UserAgent(), detail)
+}
+
+func (a *application) apiUserID(r *http.Request) int {
+	id, ok := r.Context().Value(apiUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+
+	return id
+}
+
+func (a *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
+func (a *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return err
+	}
+
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+func (a *application) errorJSON(w http.ResponseWriter, status int, message any) {
+	a.writeJSON(w, status, map[string]any{"error": message})
+}
+
+func (a *application) invalidTokenResponse(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	a.errorJSON(w, http.StatusUnauthorized, "invalid or missing API token")
+}
+
+// destroyUserSessions deletes every session of the user from the store except
+// the one with token keep, which may be empty to log the user out everywhere.
+// The sessions are found through user_sessions, every login is recorded there.
+func (a *application) destroyUserSessions(userID int, keep string) error {
+	tokens, err := a.userSessions.Tokens(userID, keep)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := a.sessionManager.Store.Delete(token); err != nil {
+			return err
+		}
+	}
+
+	return a.userSessions.DeleteForUser(userID, keep)
+}
+
+// touchSession records the current session of the user, at most once every
+// sessionTouchInterval so browsing doesn't turn into a write per request.
+func (a *application) touchSession(r *http.Request, userID int) error {
+	lastSeen := a.sessionManager.GetTime(r.Context(), "lastSeen")
+	if time.Since(lastSeen) < sessionTouchInterval {
+		return nil
+	}
+
+	token := a.sessionManager.Token(r.Context())
+	if token == "" {
+		return nil
+	}
+
+	if err := a.userSessions.Touch(token, userID, a.clientIP(r), r.UserAgent()); err != nil {
+		return err
+	}
+
+	a.sessionManager.Put(r.Context(), "lastSeen", time.Now())
+	return nil
+}
+
+// clientIP returns the IP address the request came from. X-Forwarded-For is
+// only honoured when the request was made by one of the trusted proxies, in
+// which case the right-most address not belonging to a trusted proxy is used.
+func (a *application) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !a.isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !a.isTrustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+
+	return host
+}
+
+func (a *application) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	for _, prefix := range a.trustedProxies {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a comma separated list of IP addresses and CIDR
+// ranges.
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+// recordLoginFailure audits a failed login of the user, 0 if there is no such
+// account, and counts it against both the IP address and the account,
+// writing another audit entry when either gets locked out.
+func (a *application) recordLoginFailure(r *http.Request, ip, account string, userID int, detail string) error {
+	if err := a.audit.Insert(userID, models.AuditLoginFailure, ip, r.UserAgent(), detail); err != nil {
+		return err
+	}
+
+	if a.loginIPThrottle.fail(ip) {
+		err := a.audit.Insert(0, models.AuditLoginLockout, ip, r.UserAgent(), "too many failed logins from this address")
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.loginAccountThrottle.fail(account) {
+		err := a.audit.Insert(userID, models.AuditLoginLockout, ip, r.UserAgent(), "too many failed logins for "+account)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// logIn puts the user in a fresh session, so the token used before logging
+// in can't be used to hijack it.
+func (a *application) logIn(r *http.Request, id int, method string) error {
+	if err := a.sessionManager.RenewToken(r.Context()); err != nil {
+		return err
+	}
+
+	a.sessionManager.Put(r.Context(), "id", id)
+	a.sessionManager.Put(r.Context(), "isAuthenticated", true)
+	a.sessionManager.Remove(r.Context(), "lastSeen")
+
+	if err := a.logAudit(r, id, models.AuditLoginSuccess, "logged in with "+method); err != nil {
+		return err
+	}
+
+	return a.touchSession(r, id)
+}
+
+// pageSize is how many rows the paginated lists show per page.
+const pageSize = 50
+
+// pageParams reads the ?page= parameter, returning the page number and the
+// offset of its first row.
+func pageParams(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	return page, (page - 1) * pageSize
+}
+
+// setPages fills in the previous and next page links. One more row than a
+// page holds is fetched to know whether there is a next page.
+func (d *templateData) setPages(page, rows int) {
+	if page > 1 {
+		d.PrevPage = page - 1
+	}
+	if rows > pageSize {
+		d.NextPage = page + 1
+	}
+}
+
+func idParam(r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.Atoi(params.ByName("id"))
+	return id, err == nil && id > 0
+}
+
+// parseEmbedOrigins parses the comma separated origins, like
+// https://blog.example.com, that may frame embedded snippets. "*" allows any
+// site and an empty list none.
+func parseEmbedOrigins(s string) ([]string, error) {
+	var origins []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if field == "*" {
+			origins = append(origins, field)
+			continue
+		}
+
+		u, err := url.Parse(field)
+		if err != nil {
+			return nil, err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return nil, fmt.Errorf("embed origin %q should look like https://example.com", field)
+		}
+		origins = append(origins, u.Scheme+"://"+u.Host)
+	}
+
+	return origins, nil
+}
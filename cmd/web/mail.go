@@ -0,0 +1,78 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"fmt"
+	"net/url"
+)
+
+const verifyEmailPurpose = "verify-email"
+
+type verifyEmailPayload struct {
+	UserID int `json:"uid"`
+	Email string `json:"email"`
+}
+
+// background runs fn in its own goroutine so that slow mail delivery doesn't
+// hold up the response, recovering from any panic it causes.
+func (a *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				a.errorLog.Print(fmt.Errorf("%s", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func (a *application) sendMail(recipient, subject, body string) {
+	a.background(func() {
+		if err := a.mailer.Send(recipient, subject, body); err != nil {
+			a.errorLog.Printf("sending %q to %s: %s", subject, recipient, err)
+		}
+	})
+}
+
+func (a *application) sendVerificationEmail(userID int, name, email string) error {
+	token, err := a.signer.Sign(verifyEmailPurpose, verifyEmailPayload{userID, email}, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/verify/%s", a.baseURL, url.PathEscape(token))
+	body := fmt.Sprintf(`Hi %s,
+
+Please confirm your email address for Snippetbox by opening this link:
+
+%s
+
+The link is valid for %d hours. If you didn't sign up, you can ignore this email.
+`, name, link, int(emailVerificationLifetime.Hours()))
+
+	a.sendMail(email, "Confirm your Snippetbox email address", body)
+	return nil
+}
+
+func (a *application) sendPasswordResetEmail(user *models.User) error {
+	token, err := a.passwordResets.New(user.ID, passwordResetLifetime)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/user/password/reset/%s", a.baseURL, url.PathEscape(token))
+	body := fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Snippetbox account. To choose a new
+password, open this link:
+
+%s
+
+The link is valid for %d minutes and can be used once. If you didn't ask for
+this, you can ignore this email and your password stays the same.
+`, user.Name, link, int(passwordResetLifetime.Minutes()))
+
+	a.sendMail(user.Email, "Reset your Snippetbox password", body)
+	return nil
+}
@@ -1,31 +1,91 @@
 package main
 
 import (
+	"caniteySnippetBox/internal/mailer"
 	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/secrets"
+	"caniteySnippetBox/internal/signer"
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/mysqlstore"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"github.com/go-webauthn/webauthn/webauthn"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 
+// apiTokenLifetime is how long a token generated on /account/token stays valid.
+const apiTokenLifetime = 90 * 24 * time.Hour
+
+// emailVerificationLifetime is how long the link sent after signup works.
+const emailVerificationLifetime = 48 * time.Hour
+
+// passwordResetLifetime is how long a forgotten password link works.
+const passwordResetLifetime = time.Hour
+
+// sessionTouchInterval is how often the last seen time of a session is saved.
+const sessionTouchInterval = time.Minute
+
+// shutdownTimeout is how long requests in flight get to finish when the
+// server is stopped.
+const shutdownTimeout = 10 * time.Second
+
+// viewWindow is how long a viewer looking at a snippet again doesn't count as
+// another view, and viewFlushInterval how often counted views are saved.
+const (
+	viewWindow = 30 * time.Minute
+	viewFlushInterval = time.Minute
+)
+
 type application struct {
 	errorLog *log.Logger
 	infoLog *log.Logger
 	snippets *models.SnippetModel
 	users *models.UserModel
+	tokens *models.TokenModel
+	passwordResets *models.PasswordResetModel
+	audit *models.AuditModel
+	recoveryCodes *models.RecoveryCodeModel
+	passkeys *models.PasskeyModel
+	reports *models.ReportModel
+	tags *models.TagModel
+	collections *models.CollectionModel
+	stars *models.StarModel
+	comments *models.CommentModel
+	views *models.ViewModel
+	identities *models.IdentityModel
+	userSessions *models.UserSessionModel
+	viewCounter *viewCounter
 	templateCache map[string]*template.Template
 	formDecoder *form.Decoder
 	sessionManager *scs.SessionManager
+	loginIPThrottle *loginThrottle
+	loginAccountThrottle *loginThrottle
+	limiterEnabled bool
+	reportThreshold int
+	trustedProxies []netip.Prefix
+	mailer mailer.Mailer
+	webauthn *webauthn.WebAuthn
+	oidc *oidcClient
+	signer *signer.Signer
+	scanner *secrets.Scanner
+	baseURL string
+	embedOrigins []string
 	debug bool
 }
 
@@ -33,6 +93,23 @@ func main() {
 	address := flag.String("addr", ":8888", "HTTP address")
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL")
 	debug := flag.Bool("debug", false, "enable debug mode")
+	baseURL := flag.String("base-url", "http://localhost:8888", "public URL of the site, used in emailed links")
+	secret := flag.String("secret", "", "key used to sign emailed tokens (random if empty)")
+	smtpHost := flag.String("smtp-host", "", "SMTP host (emails are logged if empty)")
+	smtpPort := flag.Int("smtp-port", 587, "SMTP port")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpSender := flag.String("smtp-sender", "Snippetbox <no-reply@snippetbox.local>", "SMTP sender")
+	mailDir := flag.String("mail-dir", "", "write emails to this directory instead of sending them")
+	limiterEnabled := flag.Bool("limiter", true, "enable rate limiting")
+	reportThreshold := flag.Int("report-threshold", 3, "hide snippets reported by this many users until a moderator looks at them (0 to never)")
+	oidcIssuer := flag.String("oidc-issuer", "", "OpenID Connect issuer URL (single sign-on is off if empty)")
+	oidcClientID := flag.String("oidc-client-id", "", "OpenID Connect client id")
+	oidcClientSecret := flag.String("oidc-client-secret", "", "OpenID Connect client secret")
+	oidcName := flag.String("oidc-name", "single sign-on", "name of the identity provider shown on the login page")
+	auditRetention := flag.Duration("audit-retention", 90*24*time.Hour, "how long audit log entries are kept")
+	trustedProxiesFlag := flag.String("trusted-proxies", "", "comma separated IPs or CIDRs of proxies whose X-Forwarded-For is trusted")
+	embedOriginsFlag := flag.String("embed-origins", "*", "comma separated origins allowed to frame embedded snippets, * for any site")
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
@@ -52,22 +129,116 @@ func main() {
 		errorLog.Fatal(err)
 	}
 
+	trustedProxies, err := parseTrustedProxies(*trustedProxiesFlag)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	embedOrigins, err := parseEmbedOrigins(*embedOriginsFlag)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// signing key for emailed tokens
+	key := []byte(*secret)
+	if len(key) == 0 {
+		infoLog.Print("no -secret given, emailed links will stop working after a restart")
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
+	var m mailer.Mailer
+	switch {
+	case *smtpHost != "":
+		m = &mailer.SMTPMailer{Host: *smtpHost, Port: *smtpPort, Username: *smtpUsername, Password: *smtpPassword, Sender: *smtpSender}
+	case *mailDir != "":
+		m = &mailer.FileMailer{Dir: *mailDir, Sender: *smtpSender}
+	default:
+		m = &mailer.LogMailer{Logger: infoLog, Sender: *smtpSender}
+	}
+
+	// passkeys are bound to the host name of -base-url
+	webAuthn, err := newWebAuthn(*baseURL)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	var oidc *oidcClient
+	if *oidcIssuer != "" {
+		redirectURL := strings.TrimRight(*baseURL, "/") + "/auth/oidc/callback"
+		oidc, err = newOIDCClient(context.Background(), *oidcIssuer, *oidcClientID, *oidcClientSecret, redirectURL, *oidcName)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+	}
+
 	// create a session manager
 	sessionManager := scs.New()
 	sessionManager.Store = mysqlstore.New(db)
 	sessionManager.Lifetime = 12 * time.Hour
 
 	app := &application{
-		errorLog,
-		infoLog,
-		&models.SnippetModel{DB:db},
-		&models.UserModel{DB:db},
-		templateCache,
-		form.NewDecoder(),
-		sessionManager,
-		*debug,
+		errorLog: errorLog,
+		infoLog: infoLog,
+		snippets: &models.SnippetModel{DB:db},
+		users: &models.UserModel{DB:db},
+		tokens: &models.TokenModel{DB:db},
+		passwordResets: &models.PasswordResetModel{DB:db},
+		audit: &models.AuditModel{DB:db},
+		recoveryCodes: &models.RecoveryCodeModel{DB:db},
+		passkeys: &models.PasskeyModel{DB:db},
+		reports: &models.ReportModel{DB:db},
+		tags: &models.TagModel{DB:db},
+		collections: &models.CollectionModel{DB:db},
+		stars: &models.StarModel{DB:db},
+		comments: &models.CommentModel{DB:db},
+		views: &models.ViewModel{DB:db},
+		identities: &models.IdentityModel{DB:db},
+		userSessions: &models.UserSessionModel{DB:db},
+		viewCounter: newViewCounter(viewWindow),
+		templateCache: templateCache,
+		formDecoder: form.NewDecoder(),
+		sessionManager: sessionManager,
+		// an address can be shared by many users, so it gets more leeway than a single account
+		loginIPThrottle: newLoginThrottle(10, 50, 15*time.Minute),
+		loginAccountThrottle: newLoginThrottle(3, 10, 15*time.Minute),
+		limiterEnabled: *limiterEnabled,
+		reportThreshold: *reportThreshold,
+		trustedProxies: trustedProxies,
+		mailer: m,
+		webauthn: webAuthn,
+		oidc: oidc,
+		signer: signer.New(key),
+		scanner: secrets.Default(),
+		baseURL: strings.TrimRight(*baseURL, "/"),
+		embedOrigins: embedOrigins,
+		debug: *debug,
 	}
 
+	// forget the metadata of sessions that expired or were destroyed, and
+	// audit log entries past their retention
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := app.userSessions.DeleteStale(); err != nil {
+				errorLog.Print(err)
+			}
+			if _, err := app.audit.DeleteBefore(time.Now().Add(-*auditRetention)); err != nil {
+				errorLog.Print(err)
+			}
+		}
+	}()
+
+	// views are counted in memory and saved in batches, rather than with a
+	// write for every page view, and once more when the server stops
+	viewsCtx, stopViews := context.WithCancel(context.Background())
+	viewsDone := make(chan struct{})
+	go func() {
+		app.flushViewsEvery(viewsCtx, viewFlushInterval)
+		close(viewsDone)
+	}()
+
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
 	}
@@ -83,11 +254,31 @@ func main() {
 		ReadTimeout: 5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
+	// stop on SIGINT or SIGTERM, letting the requests in flight finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
 	infoLog.Printf("Serving at %s", *address)
 
 	err = srv.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+	if err = <-shutdownErr; err != nil {
+		errorLog.Print(err)
+	}
 
-	errorLog.Fatal(err)
+	// no more requests can count views, save the last ones
+	stopViews()
+	<-viewsDone
 }
 
 func openDB(dsn string) (*sql.DB, error) {
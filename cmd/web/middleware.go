@@ -1,11 +1,20 @@
 package main
 
 import (
+	"caniteySnippetBox/internal/models"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/justinas/nosurf"
+	"golang.org/x/time/rate"
 )
 
 func noSurf(next http.Handler) http.Handler {
@@ -31,6 +40,23 @@ func secureHeaders(next http.Handler) http.Handler {
 	})
 }
 
+// embedHeaders lets the sites in -embed-origins frame the page. It runs after
+// secureHeaders and replaces its deny, X-Frame-Options can't name several
+// sites so frame-ancestors does the work.
+func (a *application) embedHeaders(next http.Handler) http.Handler {
+	ancestors := "'none'"
+	if len(a.embedOrigins) > 0 {
+		ancestors = strings.Join(a.embedOrigins, " ")
+	}
+	policy := "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com; frame-ancestors " + ancestors
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", policy)
+		w.Header().Del("X-Frame-Options")
+
+		next.ServeHTTP(w, r)
+	})
+}
 
 func (a *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -67,6 +93,39 @@ func (a *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// requireRole only lets users with one of the roles through. It goes after
+// requireAuthentication.
+func (a *application) requireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !slices.Contains(roles, a.userRole(r)) {
+				a.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a *application) requireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := a.users.Get(a.sessionManager.GetInt(r.Context(), "id"))
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+
+		if !user.EmailVerified {
+			a.sessionManager.Put(r.Context(), "flash", "Please verify your email address before creating snippets")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (a *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := a.sessionManager.GetInt(r.Context(), "id")
@@ -75,18 +134,120 @@ func (a *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		exists, err := a.users.Exists(id)
-		if err != nil {
+		// disabled accounts are treated like deleted ones
+		role, err := a.users.Role(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
 			a.serverError(w, err)
 			return
 		}
 
-		if exists {
+		if err == nil {
+			if err := a.touchSession(r, id); err != nil {
+				a.serverError(w, err)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, userRoleContextKey, role)
 			r = r.WithContext(ctx)
 		}
 
-
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (a *application) requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		plaintext, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || plaintext == "" {
+			a.invalidTokenResponse(w)
+			return
+		}
+
+		userID, err := a.tokens.UserID(plaintext)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				a.invalidTokenResponse(w)
+			} else {
+				a.serverError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiUserIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rateLimit returns a middleware allowing every client a token bucket of burst
+// requests refilled at limit. Clients are identified by their user id when
+// logged in (or using an API token) and by IP address otherwise. Each call
+// creates independent buckets, so every route group gets its own budget.
+func (a *application) rateLimit(limit rate.Limit, burst int) func(http.Handler) http.Handler {
+	type client struct {
+		limiter *rate.Limiter
+		lastSeen time.Time
+	}
+
+	var (
+		mu sync.Mutex
+		clients = make(map[string]*client)
+		lastSweep time.Time
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !a.limiterEnabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + a.clientIP(r)
+			if id := a.apiUserID(r); id != 0 {
+				key = "user:" + strconv.Itoa(id)
+			} else if a.IsAuthenticated(r) {
+				key = "user:" + strconv.Itoa(a.sessionManager.GetInt(r.Context(), "id"))
+			}
+
+			now := time.Now()
+
+			mu.Lock()
+			// forget idle clients as requests come in, so the buckets go away
+			// with the handler instead of needing a goroutine of their own
+			if now.Sub(lastSweep) > time.Minute {
+				for k, c := range clients {
+					if now.Sub(c.lastSeen) > 3*time.Minute {
+						delete(clients, k)
+					}
+				}
+				lastSweep = now
+			}
+			c, ok := clients[key]
+			if !ok {
+				c = &client{limiter: rate.NewLimiter(limit, burst)}
+				clients[key] = c
+			}
+			c.lastSeen = now
+			reservation := c.limiter.ReserveN(now, 1)
+			delay := reservation.DelayFrom(now)
+			if delay > 0 {
+				reservation.CancelAt(now)
+			}
+			mu.Unlock()
+
+			if delay > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					a.errorJSON(w, http.StatusTooManyRequests, "rate limit exceeded")
+				} else {
+					a.clientError(w, http.StatusTooManyRequests)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"caniteySnippetBox/internal/assert"
+
+	"github.com/alexedwards/scs/v2"
+	"golang.org/x/time/rate"
 )
 
 func TestSecureHeaders(t *testing.T) {
@@ -50,3 +57,169 @@ func TestSecureHeaders(t *testing.T) {
 
 	})
 }
+
+func TestRateLimit(t *testing.T) {
+	app := &application{limiterEnabled: true}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	handler := app.rateLimit(rate.Every(time.Minute), 2)(next)
+
+	request := func(remoteAddr string) *http.Response {
+		rr := httptest.NewRecorder()
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RemoteAddr = remoteAddr
+		handler.ServeHTTP(rr, r)
+		return rr.Result()
+	}
+
+	assert.Equal(t, request("192.0.2.1:1234").StatusCode, http.StatusOK)
+	assert.Equal(t, request("192.0.2.1:1235").StatusCode, http.StatusOK)
+
+	rs := request("192.0.2.1:1236")
+	assert.Equal(t, rs.StatusCode, http.StatusTooManyRequests)
+	assert.Equal(t, rs.Header.Get("Retry-After"), "60")
+
+	// another client has its own bucket
+	assert.Equal(t, request("192.0.2.2:1234").StatusCode, http.StatusOK)
+}
+
+func TestAPIRateLimitBeforeToken(t *testing.T) {
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		infoLog: log.New(io.Discard, "", 0),
+		sessionManager: scs.New(),
+		limiterEnabled: true,
+	}
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	// requests without a valid token still use up the budget of the address
+	status := 0
+	for i := 0; i < 41; i++ {
+		rs, err := ts.Client().Get(ts.URL + "/api/snippets")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+		status = rs.StatusCode
+	}
+
+	assert.Equal(t, status, http.StatusTooManyRequests)
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.0.2.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{trustedProxies: proxies}
+
+	tests := []struct {
+		name string
+		remoteAddr string
+		forwardedFor string
+		want string
+	}{
+		{name: "Direct", remoteAddr: "198.51.100.1:4000", want: "198.51.100.1"},
+		{name: "Untrusted proxy", remoteAddr: "198.51.100.1:4000", forwardedFor: "203.0.113.5", want: "198.51.100.1"},
+		{name: "Trusted proxy", remoteAddr: "192.0.2.10:4000", forwardedFor: "203.0.113.5", want: "203.0.113.5"},
+		{name: "Spoofed hop", remoteAddr: "10.1.1.1:4000", forwardedFor: "1.1.1.1, 203.0.113.5, 10.2.2.2", want: "203.0.113.5"},
+		{name: "Only proxies", remoteAddr: "10.1.1.1:4000", forwardedFor: "10.2.2.2", want: "10.2.2.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			assert.Equal(t, app.clientIP(r), tt.want)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	app := &application{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	handler := app.requireRole("moderator", "admin")(next)
+
+	tests := []struct {
+		name string
+		role string
+		wantCode int
+	}{
+		{name: "Admin", role: "admin", wantCode: http.StatusOK},
+		{name: "Moderator", role: "moderator", wantCode: http.StatusOK},
+		{name: "User", role: "user", wantCode: http.StatusForbidden},
+		{name: "Anonymous", role: "", wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodGet, "/admin", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.role != "" {
+				r = r.WithContext(context.WithValue(r.Context(), userRoleContextKey, tt.role))
+			}
+
+			handler.ServeHTTP(rr, r)
+			assert.Equal(t, rr.Code, tt.wantCode)
+		})
+	}
+}
+
+func TestEmbedHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+
+	tests := []struct {
+		name string
+		origins string
+		wantAncestors string
+		wantError bool
+	}{
+		{name: "Any site", origins: "*", wantAncestors: "frame-ancestors *"},
+		{name: "Allowlist", origins: "https://blog.example.com, http://localhost:3000/", wantAncestors: "frame-ancestors https://blog.example.com http://localhost:3000"},
+		{name: "Nobody", origins: "", wantAncestors: "frame-ancestors 'none'"},
+		{name: "Path", origins: "https://example.com/blog", wantError: true},
+		{name: "No scheme", origins: "example.com", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origins, err := parseEmbedOrigins(tt.origins)
+			assert.Equal(t, err != nil, tt.wantError)
+			if tt.wantError {
+				return
+			}
+
+			app := &application{embedOrigins: origins}
+			rr := httptest.NewRecorder()
+			r, err := http.NewRequest(http.MethodGet, "/snippet/embed/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			secureHeaders(app.embedHeaders(next)).ServeHTTP(rr, r)
+			rs := rr.Result()
+			assert.Equal(t, strings.HasSuffix(rs.Header.Get("Content-Security-Policy"), "; "+tt.wantAncestors), true)
+			assert.Equal(t, len(rs.Header.Values("Content-Security-Policy")), 1)
+			assert.Equal(t, rs.Header.Get("X-Frame-Options"), "")
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// oidcClient logs users in through an external OpenID Connect provider using
+// the authorization code flow with PKCE.
+type oidcClient struct {
+	issuer string
+	name string
+	config oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type oidcClaims struct {
+	Subject string `json:"sub"`
+	Email string `json:"email"`
+	EmailVerified bool `json:"email_verified"`
+	Name string `json:"name"`
+	Nonce string `json:"nonce"`
+}
+
+func newOIDCClient(ctx context.Context, issuer, clientID, clientSecret, redirectURL, name string) (*oidcClient, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oidcClient{
+		issuer: issuer,
+		name: name,
+		config: oauth2.Config{
+			ClientID: clientID,
+			ClientSecret: clientSecret,
+			RedirectURL: redirectURL,
+			Endpoint: provider.Endpoint(),
+			Scopes: []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// authCodeURL returns the provider URL to send the user to.
+func (c *oidcClient) authCodeURL(state, nonce, verifier string) string {
+	return c.config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// exchange redeems the authorization code and returns the claims of the
+// verified ID token.
+func (c *oidcClient) exchange(ctx context.Context, code, verifier, nonce string) (*oidcClaims, error) {
+	token, err := c.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc: no id_token in token response")
+	}
+
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims oidcClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+
+	return &claims, nil
+}
+
+func (a *application) oidcStart(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		a.notFound(w)
+		return
+	}
+
+	var values [3]string
+	for i := range values {
+		v, err := randomString()
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	a.sessionManager.Put(r.Context(), "oidcState", state)
+	a.sessionManager.Put(r.Context(), "oidcNonce", nonce)
+	a.sessionManager.Put(r.Context(), "oidcVerifier", verifier)
+
+	http.Redirect(w, r, a.oidc.authCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+func (a *application) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if a.oidc == nil {
+		a.notFound(w)
+		return
+	}
+
+	state := a.sessionManager.PopString(r.Context(), "oidcState")
+	nonce := a.sessionManager.PopString(r.Context(), "oidcNonce")
+	verifier := a.sessionManager.PopString(r.Context(), "oidcVerifier")
+
+	fail := func(message string) {
+		a.sessionManager.Put(r.Context(), "flash", message)
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		fail("Single sign-on was cancelled or failed")
+		return
+	}
+
+	if state == "" || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1 {
+		fail("Single sign-on failed, please try again")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	claims, err := a.oidc.exchange(ctx, query.Get("code"), verifier, nonce)
+	if err != nil {
+		a.errorLog.Printf("oidc: %s", err)
+		fail("Single sign-on failed, please try again")
+		return
+	}
+
+	id, err := a.oidcUser(r, claims)
+	if err != nil {
+		var linkErr oidcLinkError
+		if errors.As(err, &linkErr) {
+			fail(string(linkErr))
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if user.Disabled {
+		fail("This account has been disabled")
+		return
+	}
+
+	if user.TOTPEnabled {
+		if err = a.startTwoFactorLogin(r, id); err != nil {
+			a.serverError(w, err)
+			return
+		}
+		http.Redirect(w, r, "/user/login/2fa", http.StatusSeeOther)
+		return
+	}
+
+	if err = a.logIn(r, id, a.oidc.name); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// oidcLinkError is a reason the provider identity can't be used that is safe
+// to show to the user.
+type oidcLinkError string
+
+func (e oidcLinkError) Error() string { return string(e) }
+
+// oidcUser finds the user for the provider identity. Identities seen before
+// are looked up by issuer and subject; new ones are linked to the account
+// with the same verified email, or get a new account.
+func (a *application) oidcUser(r *http.Request, claims *oidcClaims) (int, error) {
+	id, err := a.identities.UserID(a.oidc.issuer, claims.Subject)
+	if err == nil {
+		return id, nil
+	} else if !errors.Is(err, models.ErrNoRecord) {
+		return 0, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, oidcLinkError("Your identity provider didn't share a verified email address")
+	}
+
+	user, err := a.users.GetByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// Anyone can sign up with any address, so an account that never
+		// proved it owns the address must not be handed to the provider
+		// identity.
+		if !user.EmailVerified {
+			return 0, oidcLinkError(fmt.Sprintf("An unverified account already uses %s, verify it or reset its password first", claims.Email))
+		}
+		id = user.ID
+	case errors.Is(err, models.ErrNoRecord):
+		id, err = a.oidcSignup(r, claims)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	if err := a.identities.Insert(id, a.oidc.issuer, claims.Subject); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// oidcSignup creates an account for a new provider identity. It gets a random
+// password, which can be replaced through the forgotten password flow.
+func (a *application) oidcSignup(r *http.Request, claims *oidcClaims) (int, error) {
+	password, err := randomString()
+	if err != nil {
+		return 0, err
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Email
+	}
+
+	id, err := a.users.Insert(name, claims.Email, password)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := a.users.VerifyEmail(id, claims.Email); err != nil {
+		return 0, err
+	}
+
+	if err := a.logAudit(r, id, models.AuditSignup, "signed up with "+a.oidc.name+" as "+claims.Email); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
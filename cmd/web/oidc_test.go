@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// mockIssuer is a minimal OpenID Connect provider: discovery, JWKS and a
+// token endpoint that checks the PKCE verifier and returns a signed ID token.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+	signingKey *rsa.PrivateKey
+	challenge string
+	nonce string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{key: key, signingKey: key}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer": m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint": m.URL + "/token",
+			"jwks_uri": m.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": "test",
+				"n": base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type": "Bearer",
+			"expires_in": 3600,
+			"id_token": m.idToken(t),
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockIssuer) idToken(t *testing.T) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "typ": "JWT", "kid": "test"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": m.URL,
+		"aud": "snippetbox",
+		"sub": "user-1",
+		"email": "alice@example.com",
+		"email_verified": true,
+		"name": "Alice",
+		"nonce": m.nonce,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.signingKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCClient(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	client, err := newOIDCClient(context.Background(), issuer.URL, "snippetbox", "secret", "https://snippetbox.test/auth/oidc/callback", "Test IdP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// begin a login the way oidcStart does and let the issuer remember the
+	// PKCE challenge and nonce it was given
+	begin := func(t *testing.T, nonce, verifier string) {
+		u, err := url.Parse(client.authCodeURL("state", nonce, verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		assert.Equal(t, u.Path, "/authorize")
+		assert.Equal(t, q.Get("state"), "state")
+		assert.Equal(t, q.Get("code_challenge_method"), "S256")
+		assert.Equal(t, q.Get("redirect_uri"), "https://snippetbox.test/auth/oidc/callback")
+		issuer.challenge = q.Get("code_challenge")
+		issuer.nonce = q.Get("nonce")
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		begin(t, "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		claims, err := client.exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, claims.Subject, "user-1")
+		assert.Equal(t, claims.Email, "alice@example.com")
+		assert.Equal(t, claims.EmailVerified, true)
+		assert.Equal(t, claims.Name, "Alice")
+	})
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		begin(t, "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		_, err := client.exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "nonce-2")
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Wrong PKCE verifier", func(t *testing.T) {
+		begin(t, "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		_, err := client.exchange(context.Background(), "good-code", "another-verifier-another-verifier-another", "nonce-1")
+		assert.Equal(t, err != nil, true)
+	})
+
+	t.Run("Wrong signing key", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issuer.signingKey = other
+		defer func() { issuer.signingKey = issuer.key }()
+
+		begin(t, "nonce-1", "verifier-verifier-verifier-verifier-verifier")
+		_, err = client.exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+		assert.Equal(t, err != nil, true)
+	})
+}
+
+func TestOIDCHandlers(t *testing.T) {
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	client, err := newOIDCClient(context.Background(), issuer.URL, "snippetbox", "secret", "https://snippetbox.test/auth/oidc/callback", "Test IdP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		infoLog: log.New(io.Discard, "", 0),
+		sessionManager: scs.New(),
+		oidc: client,
+	}
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := ts.Client()
+	c.Jar = jar
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	t.Run("Start", func(t *testing.T) {
+		rs, err := c.Get(ts.URL + "/auth/oidc/start")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, rs.StatusCode, http.StatusFound)
+
+		u, err := url.Parse(rs.Header.Get("Location"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, u.Host, strings.TrimPrefix(issuer.URL, "http://"))
+		assert.Equal(t, u.Query().Get("code_challenge_method"), "S256")
+		assert.Equal(t, u.Query().Get("state") != "", true)
+		assert.Equal(t, u.Query().Get("nonce") != "", true)
+	})
+
+	t.Run("Callback with wrong state", func(t *testing.T) {
+		rs, err := c.Get(ts.URL + "/auth/oidc/callback?code=good-code&state=forged")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, rs.StatusCode, http.StatusSeeOther)
+		assert.Equal(t, rs.Header.Get("Location"), "/user/login")
+	})
+}
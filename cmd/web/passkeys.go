@@ -0,0 +1,283 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/julienschmidt/httprouter"
+)
+
+// webauthnUser adapts a user and their passkeys to webauthn.User. The user
+// handle is the decimal user id, which is all FinishDiscoverableLogin needs to
+// find the account again.
+type webauthnUser struct {
+	user *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte { return []byte(strconv.Itoa(u.user.ID)) }
+func (u *webauthnUser) WebAuthnName() string { return u.user.Email }
+func (u *webauthnUser) WebAuthnDisplayName() string { return u.user.Name }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+func (u *webauthnUser) WebAuthnIcon() string { return "" }
+
+// newWebAuthn configures the relying party from the public URL of the site.
+func newWebAuthn(baseURL string) (*webauthn.WebAuthn, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPDisplayName: "Snippetbox",
+		RPID: u.Hostname(),
+		RPOrigins: []string{u.Scheme + "://" + u.Host},
+	})
+}
+
+func (a *application) loadWebAuthnUser(id int) (*webauthnUser, []*models.Passkey, error) {
+	user, err := a.users.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passkeys, err := a.passkeys.ForUser(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wu := &webauthnUser{user: user}
+	for _, p := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(p.Credential, &credential); err != nil {
+			return nil, nil, err
+		}
+		wu.credentials = append(wu.credentials, credential)
+	}
+
+	return wu, passkeys, nil
+}
+
+// putWebAuthnSession and popWebAuthnSession keep the challenge of a ceremony
+// in the scs session between its begin and finish requests.
+func (a *application) putWebAuthnSession(r *http.Request, key string, session *webauthn.SessionData) error {
+	js, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	a.sessionManager.Put(r.Context(), key, string(js))
+	return nil
+}
+
+func (a *application) popWebAuthnSession(r *http.Request, key string) (webauthn.SessionData, error) {
+	var session webauthn.SessionData
+	js := a.sessionManager.PopString(r.Context(), key)
+	if js == "" {
+		return session, models.ErrNoRecord
+	}
+	err := json.Unmarshal([]byte(js), &session)
+	return session, err
+}
+
+func (a *application) accountPasskeys(w http.ResponseWriter, r *http.Request) {
+	passkeys, err := a.passkeys.ForUser(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Passkeys = passkeys
+	a.render(w, http.StatusOK, "passkeys.tmpl", data)
+}
+
+func (a *application) accountPasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	wu, _, err := a.loadWebAuthnUser(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, c := range wu.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	options, session, err := a.webauthn.BeginRegistration(wu,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err := a.putWebAuthnSession(r, "webauthnRegistration", session); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, options)
+}
+
+func (a *application) accountPasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+
+	session, err := a.popWebAuthnSession(r, "webauthnRegistration")
+	if err != nil {
+		a.errorJSON(w, http.StatusBadRequest, "no registration in progress")
+		return
+	}
+
+	wu, _, err := a.loadWebAuthnUser(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	credential, err := a.webauthn.FinishRegistration(wu, session, r)
+	if err != nil {
+		a.errorJSON(w, http.StatusBadRequest, "the passkey could not be verified")
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = "Passkey"
+	}
+	if len([]rune(name)) > 100 {
+		name = string([]rune(name)[:100])
+	}
+
+	js, err := json.Marshal(credential)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err := a.passkeys.Insert(id, name, credential.ID, js); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Your passkey has been added")
+	a.writeJSON(w, http.StatusCreated, map[string]any{"redirect": "/account/passkeys"})
+}
+
+func (a *application) accountPasskeyDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	passkeyID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || passkeyID < 1 {
+		a.notFound(w)
+		return
+	}
+
+	err = a.passkeys.Delete(passkeyID, a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The passkey has been removed")
+	http.Redirect(w, r, "/account/passkeys", http.StatusSeeOther)
+}
+
+func (a *application) userLoginPasskeyBegin(w http.ResponseWriter, r *http.Request) {
+	// a passkey replaces both the password and the second factor, so the
+	// authenticator has to verify the user (PIN, biometrics) itself
+	options, session, err := a.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err := a.putWebAuthnSession(r, "webauthnLogin", session); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, options)
+}
+
+func (a *application) userLoginPasskeyFinish(w http.ResponseWriter, r *http.Request) {
+	session, err := a.popWebAuthnSession(r, "webauthnLogin")
+	if err != nil {
+		a.errorJSON(w, http.StatusBadRequest, "no login in progress")
+		return
+	}
+
+	var (
+		userID int
+		disabled bool
+		passkeys []*models.Passkey
+	)
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		id, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		wu, pks, err := a.loadWebAuthnUser(id)
+		if err != nil {
+			return nil, err
+		}
+
+		userID, disabled, passkeys = id, wu.user.Disabled, pks
+		return wu, nil
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	credential, err := a.webauthn.FinishDiscoverableLogin(handler, session, r)
+	if err != nil {
+		a.errorJSON(w, http.StatusUnauthorized, "the passkey could not be verified")
+		return
+	}
+
+	// a signature counter going backwards means the key may have been copied
+	if credential.Authenticator.CloneWarning {
+		a.errorJSON(w, http.StatusUnauthorized, "this passkey looks cloned, log in with your password and replace it")
+		return
+	}
+
+	if disabled {
+		a.errorJSON(w, http.StatusForbidden, "this account has been disabled")
+		return
+	}
+
+	for _, p := range passkeys {
+		if string(p.CredentialID) != string(credential.ID) {
+			continue
+		}
+
+		js, err := json.Marshal(credential)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		if err := a.passkeys.Used(p.ID, js); err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
+	if err := a.logIn(r, userID, "passkey"); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, map[string]any{"redirect": "/account/view"})
+}
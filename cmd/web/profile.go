@@ -0,0 +1,177 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type accountProfileForm struct {
+	Name string `form:"name"`
+	Email string `form:"email"`
+	Password string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type accountDeleteForm struct {
+	Password string `form:"password"`
+	Snippets string `form:"snippets"`
+	validator.Validator `form:"-"`
+}
+
+func (a *application) accountProfile(w http.ResponseWriter, r *http.Request) {
+	user, err := a.users.Get(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Form = accountProfileForm{Name: user.Name, Email: user.Email}
+	a.render(w, http.StatusOK, "profile.tmpl", data)
+}
+
+func (a *application) accountProfilePost(w http.ResponseWriter, r *http.Request) {
+	var form accountProfileForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	form.Name = strings.TrimSpace(form.Name)
+	form.Email = strings.TrimSpace(form.Email)
+	emailChanged := form.Email != user.Email
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be empty")
+	form.CheckField(validator.MaxChars(form.Name, 255), "name", "This field cannot be more than 255 characters long")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be empty")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Not a valid email address")
+	// the email is what password resets go to, so whoever changes it has to
+	// know the password and not just hold the session
+	if emailChanged {
+		form.CheckField(validator.NotBlank(form.Password), "password", "Enter your password to change your email")
+	}
+
+	render := func() {
+		form.Password = ""
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "profile.tmpl", data)
+	}
+
+	if !form.Valid() {
+		render()
+		return
+	}
+
+	if emailChanged {
+		if err := a.users.CheckPassword(id, form.Password); err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				form.AddFieldError("password", "Password is wrong")
+				render()
+			} else {
+				a.serverError(w, err)
+			}
+			return
+		}
+	}
+
+	if err := a.users.UpdateProfile(id, form.Name, form.Email); err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "email already in use")
+			render()
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	flash := "Your profile has been updated"
+	if emailChanged {
+		if err := a.sendVerificationEmail(id, form.Name, form.Email); err != nil {
+			a.serverError(w, err)
+			return
+		}
+		flash = "Your profile has been updated, check your inbox to verify your new email"
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+func (a *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := a.newTemplateData(r)
+	data.Form = accountDeleteForm{Snippets: "delete"}
+	a.render(w, http.StatusOK, "delete.tmpl", data)
+}
+
+func (a *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	var form accountDeleteForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be empty")
+	form.CheckField(validator.PermittedValue(form.Snippets, "delete", "keep"), "snippets", "Choose what happens to your snippets")
+
+	render := func() {
+		form.Password = ""
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "delete.tmpl", data)
+	}
+
+	if !form.Valid() {
+		render()
+		return
+	}
+
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	if err := a.users.CheckPassword(id, form.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("password", "Password is wrong")
+			render()
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if err := a.deleteAccount(id, form.Snippets == "keep", a.sessionManager.Token(r.Context())); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// the current session is replaced rather than destroyed so the flash
+	// survives
+	if err := a.sessionManager.RenewToken(r.Context()); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	a.sessionManager.Remove(r.Context(), "id")
+	a.sessionManager.Remove(r.Context(), "lastSeen")
+
+	a.sessionManager.Put(r.Context(), "flash", "Your account has been deleted")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// deleteAccount logs the user out everywhere but the session with token keep,
+// then deletes the account. The sessions go first, they are found through
+// user_sessions and deleting the user cascades to it.
+func (a *application) deleteAccount(id int, keepSnippets bool, keep string) error {
+	if err := a.destroyUserSessions(id, keep); err != nil {
+		return err
+	}
+
+	return a.users.Delete(id, keepSnippets)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+	"caniteySnippetBox/internal/models"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// accountDB is a database/sql driver that only knows the session tokens of one
+// user. Like MySQL, deleting the user cascades to user_sessions.
+type accountDB struct {
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (d *accountDB) Open(name string) (driver.Conn, error) { return accountConn{d}, nil }
+
+type accountConn struct{ db *accountDB }
+
+func (c accountConn) Prepare(query string) (driver.Stmt, error) {
+	return accountStmt{c.db, query}, nil
+}
+func (c accountConn) Close() error              { return nil }
+func (c accountConn) Begin() (driver.Tx, error) { return accountTx{}, nil }
+
+type accountTx struct{}
+
+func (accountTx) Commit() error   { return nil }
+func (accountTx) Rollback() error { return nil }
+
+type accountStmt struct {
+	db    *accountDB
+	query string
+}
+
+func (s accountStmt) Close() error  { return nil }
+func (s accountStmt) NumInput() int { return -1 }
+
+func (s accountStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if strings.HasPrefix(s.query, "DELETE FROM users") || strings.HasPrefix(s.query, "DELETE FROM user_sessions") {
+		s.db.tokens = nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s accountStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	var tokens []string
+	if strings.Contains(s.query, "FROM user_sessions") {
+		for _, token := range s.db.tokens {
+			if token != args[1] {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return &accountRows{tokens: tokens}, nil
+}
+
+type accountRows struct{ tokens []string }
+
+func (r *accountRows) Columns() []string { return []string{"token"} }
+func (r *accountRows) Close() error      { return nil }
+
+func (r *accountRows) Next(dest []driver.Value) error {
+	if len(r.tokens) == 0 {
+		return io.EOF
+	}
+	dest[0], r.tokens = r.tokens[0], r.tokens[1:]
+	return nil
+}
+
+func TestDeleteAccount(t *testing.T) {
+	sql.Register("account", &accountDB{tokens: []string{"current", "laptop", "phone"}})
+	db, err := sql.Open("account", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sessionManager := scs.New()
+	for _, token := range []string{"current", "laptop", "phone"} {
+		if err := sessionManager.Store.Commit(token, []byte("session"), time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := &application{
+		errorLog:       log.New(io.Discard, "", 0),
+		infoLog:        log.New(io.Discard, "", 0),
+		sessionManager: sessionManager,
+		users:          &models.UserModel{DB: db},
+		userSessions:   &models.UserSessionModel{DB: db},
+	}
+
+	if err := app.deleteAccount(1, false, "current"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"current", true},
+		{"laptop", false},
+		{"phone", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			_, found, err := sessionManager.Store.Find(tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, found, tt.want)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var reportReasons = []string{"spam", "leaked secret", "abuse", "other"}
+
+type snippetReportForm struct {
+	Reason string `form:"reason"`
+	Details string `form:"details"`
+	validator.Validator `form:"-"`
+}
+
+// canModerate reports whether the logged in user may see hidden snippets and
+// work through the reports.
+func (a *application) canModerate(r *http.Request) bool {
+	return slices.Contains([]string{models.RoleModerator, models.RoleAdmin}, a.userRole(r))
+}
+
+// canSee reports whether the snippet may be shown to the logged in user.
+// Private snippets are only visible to their owner, hidden ones to their
+// owner and to moderators.
+func (a *application) canSee(r *http.Request, s *models.Snippet) bool {
+	owner := a.IsAuthenticated(r) && s.UserID == a.sessionManager.GetInt(r.Context(), "id")
+	switch {
+	case s.Private:
+		return owner
+	case s.Hidden:
+		return owner || a.canModerate(r)
+	default:
+		return true
+	}
+}
+
+func (a *application) snippetReportPost(w http.ResponseWriter, r *http.Request) {
+	snippetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(snippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+
+	userID := a.sessionManager.GetInt(r.Context(), "id")
+	if snippet.UserID == userID {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form snippetReportForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Details = strings.TrimSpace(form.Details)
+	form.CheckField(validator.PermittedValue(form.Reason, reportReasons...), "reason", "Choose a reason")
+	form.CheckField(validator.MaxChars(form.Details, 500), "details", "This field cannot be more than 500 characters long")
+
+	if !form.Valid() {
+		a.renderSnippet(w, r, http.StatusUnprocessableEntity, snippet, form, commentForm{})
+		return
+	}
+
+	url := fmt.Sprintf("/snippet/view/%d", snippetID)
+
+	reports, err := a.reports.Insert(snippetID, userID, form.Reason, form.Details)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateReport) {
+			a.sessionManager.Put(r.Context(), "flash", "You have already reported this snippet")
+			http.Redirect(w, r, url, http.StatusSeeOther)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	err = a.logAudit(r, userID, models.AuditSnippetReport, fmt.Sprintf("reported snippet %d as %s", snippetID, form.Reason))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	// enough people agreeing is reason to take it down before a moderator
+	// gets to it
+	if a.reportThreshold > 0 && reports >= a.reportThreshold && !snippet.Hidden {
+		if err := a.snippets.SetHidden(snippetID, true); err != nil {
+			a.serverError(w, err)
+			return
+		}
+
+		err = a.logAudit(r, 0, models.AuditSnippetAutoHide, fmt.Sprintf("snippet %d hidden after reports from %d users", snippetID, reports))
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Thanks for the report, a moderator will look at it")
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+func (a *application) adminReports(w http.ResponseWriter, r *http.Request) {
+	queue, err := a.reports.Queue()
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Reports = queue
+	a.render(w, http.StatusOK, "admin_reports.tmpl", data)
+}
+
+// adminReportDismissPost closes the reports and puts the snippet back up if
+// it was hidden.
+func (a *application) adminReportDismissPost(w http.ResponseWriter, r *http.Request) {
+	a.moderateSnippet(w, r, models.ReportDismiss, models.AuditAdminReportDismiss, "The reports have been dismissed")
+}
+
+func (a *application) adminReportHidePost(w http.ResponseWriter, r *http.Request) {
+	a.moderateSnippet(w, r, models.ReportHide, models.AuditAdminSnippetHide, "The snippet has been hidden")
+}
+
+func (a *application) adminReportDeletePost(w http.ResponseWriter, r *http.Request) {
+	a.moderateSnippet(w, r, models.ReportDelete, models.AuditAdminSnippetDelete, "The snippet has been removed")
+}
+
+// moderateSnippet applies a moderator's decision to a reported snippet,
+// resolves its reports and writes the audit entry.
+func (a *application) moderateSnippet(w http.ResponseWriter, r *http.Request, decision, event, flash string) {
+	snippetID, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	if err := a.reports.Resolve(snippetID, decision); err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	moderatorID := a.sessionManager.GetInt(r.Context(), "id")
+	if err := a.logAudit(r, moderatorID, event, fmt.Sprintf("snippet %d", snippetID)); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/admin/reports", http.StatusSeeOther)
+}
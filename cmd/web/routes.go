@@ -2,10 +2,14 @@ package main
 
 import (
 	"net/http"
+	"time"
+
+	"caniteySnippetBox/internal/models"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 	"caniteySnippetBox/ui"
+	"golang.org/x/time/rate"
 )
 
 func (a *application) routes() http.Handler {
@@ -18,24 +22,118 @@ func (a *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
 
-	dynamic := alice.New(a.sessionManager.LoadAndSave, noSurf, a.authenticate)
+	// Every rateLimit call below makes its own buckets, so each group has a
+	// budget of its own. Pages can be browsed freely, but the forms that create
+	// accounts, send emails or check passwords only allow a request every few
+	// seconds per client.
+	dynamic := alice.New(a.sessionManager.LoadAndSave, noSurf, a.authenticate, a.rateLimit(10, 40))
+	sensitive := dynamic.Append(a.rateLimit(rate.Every(6*time.Second), 5))
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(a.home))
 	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(a.about))
 	router.Handler(http.MethodGet, "/ping", dynamic.ThenFunc(a.ping))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(a.snippetView))
+	router.Handler(http.MethodGet, "/tags", dynamic.ThenFunc(a.tagIndex))
+	router.Handler(http.MethodGet, "/tags/:tag", dynamic.ThenFunc(a.tagView))
+	router.Handler(http.MethodGet, "/collection/view/:id", dynamic.ThenFunc(a.collectionView))
+	raw := alice.New(a.rateLimit(10, 40))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", raw.ThenFunc(a.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/raw/:id/:file", raw.ThenFunc(a.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/embed/:id", raw.Append(a.embedHeaders).ThenFunc(a.snippetEmbed))
+	router.Handler(http.MethodGet, "/oembed", raw.ThenFunc(a.oEmbed))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(a.userSignup))
-	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(a.userSignupPost))
+	router.Handler(http.MethodPost, "/user/signup", sensitive.ThenFunc(a.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(a.userLogin))
-	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(a.userLoginPost))
+	router.Handler(http.MethodPost, "/user/login", sensitive.ThenFunc(a.userLoginPost))
+	router.Handler(http.MethodPost, "/user/login/passkey/begin", sensitive.ThenFunc(a.userLoginPasskeyBegin))
+	router.Handler(http.MethodPost, "/user/login/passkey/finish", sensitive.ThenFunc(a.userLoginPasskeyFinish))
+	router.Handler(http.MethodGet, "/auth/oidc/start", sensitive.ThenFunc(a.oidcStart))
+	router.Handler(http.MethodGet, "/auth/oidc/callback", sensitive.ThenFunc(a.oidcCallback))
+	router.Handler(http.MethodGet, "/user/login/2fa", dynamic.ThenFunc(a.userLoginTwoFactor))
+	router.Handler(http.MethodPost, "/user/login/2fa", sensitive.ThenFunc(a.userLoginTwoFactorPost))
+	router.Handler(http.MethodGet, "/user/verify/:token", dynamic.ThenFunc(a.userVerifyEmail))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(a.userPasswordForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", sensitive.ThenFunc(a.userPasswordForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset/:token", dynamic.ThenFunc(a.userPasswordReset))
+	router.Handler(http.MethodPost, "/user/password/reset/:token", sensitive.ThenFunc(a.userPasswordResetPost))
 
 	protected := dynamic.Append(a.requireAuthentication)
-	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(a.snippetCreateForm))
-	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(a.snippetCreatePost))
+	verified := protected.Append(a.requireVerifiedEmail)
+	creating := verified.Append(a.rateLimit(rate.Every(10*time.Second), 5))
+	// comments and reports, and the account settings that check passwords or
+	// codes, each have a limit of their own
+	posting := a.rateLimit(rate.Every(6*time.Second), 5)
+	security := protected.Append(a.rateLimit(rate.Every(6*time.Second), 5))
+	router.Handler(http.MethodGet, "/snippet/create", verified.ThenFunc(a.snippetCreateForm))
+	router.Handler(http.MethodPost, "/snippet/create", creating.ThenFunc(a.snippetCreatePost))
+	router.Handler(http.MethodPost, "/snippet/fork/:id", creating.ThenFunc(a.snippetForkPost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protected.ThenFunc(a.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/comment/:id", verified.Append(posting).ThenFunc(a.snippetCommentPost))
+	router.Handler(http.MethodGet, "/comment/edit/:id", protected.ThenFunc(a.commentEdit))
+	router.Handler(http.MethodPost, "/comment/edit/:id", protected.ThenFunc(a.commentEditPost))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protected.ThenFunc(a.commentDeletePost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(a.snippetDeletePost))
+	router.Handler(http.MethodGet, "/snippet/stats/:id", protected.ThenFunc(a.snippetStats))
+	router.Handler(http.MethodPost, "/snippet/report/:id", protected.Append(posting).ThenFunc(a.snippetReportPost))
+	router.Handler(http.MethodGet, "/collections", protected.ThenFunc(a.collectionList))
+	router.Handler(http.MethodPost, "/collections", protected.ThenFunc(a.collectionCreatePost))
+	router.Handler(http.MethodPost, "/snippet/collect/:id", protected.ThenFunc(a.snippetCollectPost))
+	router.Handler(http.MethodGet, "/collection/edit/:id", protected.ThenFunc(a.collectionEdit))
+	router.Handler(http.MethodPost, "/collection/edit/:id", protected.ThenFunc(a.collectionEditPost))
+	router.Handler(http.MethodPost, "/collection/delete/:id", protected.ThenFunc(a.collectionDeletePost))
+	router.Handler(http.MethodPost, "/collection/remove/:id", protected.ThenFunc(a.collectionRemovePost))
+	router.Handler(http.MethodPost, "/collection/move/:id", protected.ThenFunc(a.collectionMovePost))
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(a.accountView))
+	router.Handler(http.MethodGet, "/account/stars", protected.ThenFunc(a.accountStars))
+	router.Handler(http.MethodPost, "/account/verify/resend", protected.Append(a.rateLimit(rate.Every(time.Minute), 2)).ThenFunc(a.accountVerifyResendPost))
+	router.Handler(http.MethodGet, "/account/profile", protected.ThenFunc(a.accountProfile))
+	router.Handler(http.MethodPost, "/account/profile", security.ThenFunc(a.accountProfilePost))
+	router.Handler(http.MethodGet, "/account/export", security.ThenFunc(a.accountExport))
+	router.Handler(http.MethodGet, "/account/delete", protected.ThenFunc(a.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", security.ThenFunc(a.accountDeletePost))
 	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(a.accountPasswordUpdate))
-	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(a.accountPasswordUpdatePost))
+	router.Handler(http.MethodPost, "/account/password/update", security.ThenFunc(a.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/2fa", protected.ThenFunc(a.accountTwoFactor))
+	router.Handler(http.MethodGet, "/account/2fa/qr.png", protected.ThenFunc(a.accountTwoFactorQR))
+	router.Handler(http.MethodPost, "/account/2fa/enable", security.ThenFunc(a.accountTwoFactorEnablePost))
+	router.Handler(http.MethodPost, "/account/2fa/recovery", security.ThenFunc(a.accountTwoFactorRecoveryPost))
+	router.Handler(http.MethodPost, "/account/2fa/disable", security.ThenFunc(a.accountTwoFactorDisablePost))
+	router.Handler(http.MethodGet, "/account/passkeys", protected.ThenFunc(a.accountPasskeys))
+	router.Handler(http.MethodPost, "/account/passkeys/register/begin", protected.ThenFunc(a.accountPasskeyRegisterBegin))
+	router.Handler(http.MethodPost, "/account/passkeys/register/finish", protected.ThenFunc(a.accountPasskeyRegisterFinish))
+	router.Handler(http.MethodPost, "/account/passkeys/delete/:id", protected.ThenFunc(a.accountPasskeyDeletePost))
+	router.Handler(http.MethodGet, "/account/activity", protected.ThenFunc(a.accountActivity))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(a.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/revoke", protected.ThenFunc(a.accountSessionsRevokeOthersPost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(a.accountSessionRevokePost))
+	router.Handler(http.MethodGet, "/account/token", protected.ThenFunc(a.accountToken))
+	router.Handler(http.MethodPost, "/account/token", protected.ThenFunc(a.accountTokenPost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(a.userLogoutPost))
+
+	// moderators look after snippets, only admins manage accounts
+	moderation := protected.Append(a.requireRole(models.RoleModerator, models.RoleAdmin))
+	administration := protected.Append(a.requireRole(models.RoleAdmin))
+	router.Handler(http.MethodGet, "/admin", moderation.ThenFunc(a.adminDashboard))
+	router.Handler(http.MethodGet, "/admin/snippets", moderation.ThenFunc(a.adminSnippets))
+	router.Handler(http.MethodPost, "/admin/snippets/delete/:id", moderation.ThenFunc(a.adminSnippetDeletePost))
+	router.Handler(http.MethodGet, "/admin/reports", moderation.ThenFunc(a.adminReports))
+	router.Handler(http.MethodPost, "/admin/reports/dismiss/:id", moderation.ThenFunc(a.adminReportDismissPost))
+	router.Handler(http.MethodPost, "/admin/reports/hide/:id", moderation.ThenFunc(a.adminReportHidePost))
+	router.Handler(http.MethodPost, "/admin/reports/delete/:id", moderation.ThenFunc(a.adminReportDeletePost))
+	router.Handler(http.MethodPost, "/admin/comments/remove/:id", moderation.ThenFunc(a.commentRemovePost))
+	router.Handler(http.MethodGet, "/admin/users", administration.ThenFunc(a.adminUsers))
+	router.Handler(http.MethodPost, "/admin/users/disable/:id", administration.ThenFunc(a.adminUserDisablePost))
+	router.Handler(http.MethodPost, "/admin/users/enable/:id", administration.ThenFunc(a.adminUserEnablePost))
+	router.Handler(http.MethodGet, "/admin/audit", administration.ThenFunc(a.adminAudit))
+
+	// the address is limited before the token is checked so tokens can't be
+	// guessed at full speed, the user after it so a token has its own budget
+	api := alice.New(a.rateLimit(10, 40), a.requireAPIToken, a.rateLimit(5, 20))
+	router.Handler(http.MethodGet, "/api/snippets", api.ThenFunc(a.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/snippets/:id/raw", api.ThenFunc(a.apiSnippetRaw))
+	router.Handler(http.MethodGet, "/api/snippets/:id/raw/:file", api.ThenFunc(a.apiSnippetRaw))
+	router.Handler(http.MethodPost, "/api/snippets", api.Append(a.rateLimit(rate.Every(10*time.Second), 5)).ThenFunc(a.apiSnippetCreate))
+
 	standard := alice.New(a.recoverPanic, a.logRequest, secureHeaders)
 
 	return standard.Then(router)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func (a *application) accountSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := a.userSessions.ForUser(a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Sessions = sessions
+
+	token := a.sessionManager.Token(r.Context())
+	for _, s := range sessions {
+		if s.Token == token {
+			data.CurrentSessionID = s.ID
+		}
+	}
+
+	a.render(w, http.StatusOK, "sessions.tmpl", data)
+}
+
+func (a *application) accountSessionRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	sessionID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || sessionID < 1 {
+		a.notFound(w)
+		return
+	}
+
+	session, err := a.userSessions.Get(sessionID, a.sessionManager.GetInt(r.Context(), "id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if session.Token == a.sessionManager.Token(r.Context()) {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err := a.sessionManager.Store.Delete(session.Token); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	if err := a.userSessions.Delete(session.Token); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "The session has been logged out")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
+
+func (a *application) accountSessionsRevokeOthersPost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+
+	err := a.destroyUserSessions(id, a.sessionManager.Token(r.Context()))
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "All other sessions have been logged out")
+	http.Redirect(w, r, "/account/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// snippetStarPost stars the snippet, or unstars it if the user already had.
+func (a *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+	if !a.canSee(r, snippet) {
+		a.notFound(w)
+		return
+	}
+
+	_, err = a.stars.Toggle(a.sessionManager.GetInt(r.Context(), "id"), id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
+func (a *application) accountStars(w http.ResponseWriter, r *http.Request) {
+	page, offset := pageParams(r)
+	snippets, err := a.stars.Snippets(a.sessionManager.GetInt(r.Context(), "id"), pageSize+1, offset)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(snippets))
+	if len(snippets) > pageSize {
+		snippets = snippets[:pageSize]
+	}
+	data.Snippets = snippets
+	a.render(w, http.StatusOK, "stars.tmpl", data)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/validator"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	maxTags = 5
+	maxTagLength = 30
+)
+
+// parseTags splits a comma or space separated list of tags, lower-casing them
+// and dropping a leading # and duplicates.
+func parseTags(s string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+		tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func checkTags(v *validator.Validator, tags []string) {
+	v.CheckField(validator.MaxItems(tags, maxTags), "tags", "No more than 5 tags")
+	v.CheckField(validator.AllMaxChars(tags, maxTagLength), "tags", "Tags cannot be more than 30 characters long")
+	v.CheckField(validator.AllMatch(tags, validator.TagRX), "tags", "Tags can only contain letters, digits and + # . -")
+}
+
+func (a *application) tagIndex(w http.ResponseWriter, r *http.Request) {
+	counts, err := a.tags.Counts()
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.TagCounts = counts
+	a.render(w, http.StatusOK, "tags.tmpl", data)
+}
+
+func (a *application) tagView(w http.ResponseWriter, r *http.Request) {
+	tag := httprouter.ParamsFromContext(r.Context()).ByName("tag")
+
+	// tags are stored lower-cased, send other spellings to the one URL
+	if lower := strings.ToLower(tag); lower != tag {
+		target := "/tags/" + url.PathEscape(lower)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	count, err := a.tags.Count(tag)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	if count == 0 {
+		a.notFound(w)
+		return
+	}
+
+	page, offset := pageParams(r)
+	snippets, err := a.tags.Snippets(tag, pageSize+1, offset)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.setPages(page, len(snippets))
+	data.Snippets = snippets[:min(len(snippets), pageSize)]
+	data.Tag = tag
+	data.Count = count
+	a.render(w, http.StatusOK, "tag.tmpl", data)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+	"caniteySnippetBox/internal/validator"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		want []string
+		valid bool
+	}{
+		{name: "Empty", input: "", want: []string{}, valid: true},
+		{name: "Commas and spaces", input: "Go, sql  testing,", want: []string{"go", "sql", "testing"}, valid: true},
+		{name: "Hashes and duplicates", input: "#go go GO c++ c#", want: []string{"go", "c++", "c#"}, valid: true},
+		{name: "Tabs, newlines and a lone hash", input: "go\tsql\r\n# node.js", want: []string{"go", "sql", "node.js"}, valid: true},
+		{name: "Five is fine", input: "a b c d e", want: []string{"a", "b", "c", "d", "e"}, valid: true},
+		{name: "Leading symbol", input: "-go", want: []string{"-go"}, valid: false},
+		{name: "Too many", input: "a b c d e f", want: []string{"a", "b", "c", "d", "e", "f"}, valid: false},
+		{name: "Bad characters", input: "go/lang", want: []string{"go/lang"}, valid: false},
+		{name: "Too long", input: strings.Repeat("x", 31), want: []string{strings.Repeat("x", 31)}, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags := parseTags(tt.input)
+			assert.Equal(t, strings.Join(tags, " "), strings.Join(tt.want, " "))
+
+			var v validator.Validator
+			checkTags(&v, tags)
+			assert.Equal(t, v.Valid(), tt.valid)
+		})
+	}
+}
+
+func TestTagViewCanonical(t *testing.T) {
+	app := &application{
+		errorLog: log.New(io.Discard, "", 0),
+		infoLog: log.New(io.Discard, "", 0),
+		sessionManager: scs.New(),
+	}
+
+	ts := httptest.NewTLSServer(app.routes())
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	rs, err := client.Get(ts.URL + "/tags/Go?page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusMovedPermanently)
+	assert.Equal(t, rs.Header.Get("Location"), "/tags/go?page=2")
+}
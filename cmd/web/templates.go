@@ -1,11 +1,13 @@
 package main
 
 import (
+	"caniteySnippetBox/internal/markdown"
 	"caniteySnippetBox/internal/models"
 	"caniteySnippetBox/ui"
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -16,19 +18,87 @@ type templateData struct {
 	Form any
 	Flash string
 	IsAuthenticated bool
+	Role string
+	UserID int
 	CSRFToken	string
 	User 	*models.User
+	Token *models.Token
+	TOTPSecret string
+	RecoveryCodes []string
+	RecoveryCodesLeft int
+	Passkeys []*models.Passkey
+	OIDCName string
+	Sessions []*models.UserSession
+	CurrentSessionID int
+	Users []*models.User
+	PrevPage int
+	NextPage int
+	AuditEntries []*models.AuditEntry
+	FilterUserID int
+	Reports []*models.ReportedSnippet
+	ReportReasons []string
+	Tag string
+	Tags []string
+	TagCounts []*models.TagCount
+	Count int
+	Forks int
+	Starred bool
+	Sort string
+	Files []*models.SnippetFile
+	SnippetFiles map[int][]*models.SnippetFile
+	Collection *models.Collection
+	Collections []*models.Collection
+	Comment *models.Comment
+	Comments []*models.Comment
+	CommentForm any
+	Languages []string
+	BaseURL string
+	ViewBars []viewBar
+	RecentViews int
 }
 
 func humanDate(t time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
-	return t.Format("02 Mar 2006 at 15:04")
+	return t.UTC().Format("02 Jan 2006 at 15:04")
+}
+
+// codeBlock is what the code partial renders. Anchor prefixes the line ids so
+// several files on one page don't collide, "" gives L1, L2...
+type codeBlock struct {
+	Anchor string
+	Language string
+	Content string
+}
+
+func newCodeBlock(anchor, language, content string) codeBlock {
+	return codeBlock{Anchor: anchor, Language: language, Content: content}
+}
+
+type codeLine struct {
+	Number int
+	Text string
+}
+
+// codeLines splits the content into numbered lines, a final newline doesn't
+// start another one.
+func codeLines(content string) []codeLine {
+	split := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	lines := make([]codeLine, len(split))
+	for i, text := range split {
+		lines[i] = codeLine{Number: i + 1, Text: strings.TrimSuffix(text, "\r")}
+	}
+	return lines
 }
 
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"codeBlock": newCodeBlock,
+	"codeLines": codeLines,
+	"embedHTML": func(baseURL string, s *models.Snippet) string { return embedHTML(baseURL, s, embedWidth, embedHeight) },
+	"commentHTML": markdown.Comment,
+	"markdownHTML": markdown.Document,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -65,3 +135,14 @@ func newTemplateCache() (map[string]*template.Template, error) {
 
 	return cache, nil
 }
+
+// commentData is what the recursive "comment" template gets: the page data
+// and the comment to render.
+type commentData struct {
+	*templateData
+	Comment *models.Comment
+}
+
+func (d *templateData) WithComment(c *models.Comment) commentData {
+	return commentData{templateData: d, Comment: c}
+}
@@ -28,3 +28,13 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+func TestNewTemplateCache(t *testing.T) {
+	cache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := cache["home.tmpl"]
+	assert.Equal(t, ok, true)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// loginThrottle tracks failed logins per key (an IP address or an email). Once
+// a key has backoffAfter consecutive failures it has to wait an exponentially
+// growing delay between attempts, and after lockoutAfter failures it is locked
+// out completely for the lockout duration.
+type loginThrottle struct {
+	mu sync.Mutex
+	entries map[string]*loginFailures
+	now func() time.Time
+	backoffAfter int
+	lockoutAfter int
+	lockout time.Duration
+	lastPrune time.Time
+}
+
+type loginFailures struct {
+	count int
+	last time.Time
+	lockedUntil time.Time
+}
+
+func newLoginThrottle(backoffAfter, lockoutAfter int, lockout time.Duration) *loginThrottle {
+	return &loginThrottle{
+		entries: make(map[string]*loginFailures),
+		now: time.Now,
+		backoffAfter: backoffAfter,
+		lockoutAfter: lockoutAfter,
+		lockout: lockout,
+	}
+}
+
+// wait returns how long key has to wait before its next attempt is allowed.
+func (t *loginThrottle) wait(key string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+
+	now := t.now()
+	if now.Before(f.lockedUntil) {
+		return f.lockedUntil.Sub(now)
+	}
+
+	if f.count < t.backoffAfter {
+		return 0
+	}
+
+	delay := time.Second << (f.count - t.backoffAfter)
+	if delay > t.lockout || delay <= 0 {
+		delay = t.lockout
+	}
+
+	if wait := f.last.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// fail records a failed attempt for key and reports whether it caused key to
+// be locked out.
+func (t *loginThrottle) fail(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+
+	f, ok := t.entries[key]
+	if !ok {
+		f = &loginFailures{}
+		t.entries[key] = f
+	}
+
+	f.count++
+	f.last = t.now()
+
+	if f.count >= t.lockoutAfter {
+		f.count = 0
+		f.lockedUntil = f.last.Add(t.lockout)
+		return true
+	}
+
+	return false
+}
+
+func (t *loginThrottle) reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// prune forgets the keys that are done waiting, at most once a minute. It is
+// called from fail, which is the only place entries are added, with t.mu held.
+func (t *loginThrottle) prune() {
+	now := t.now()
+	if now.Sub(t.lastPrune) < time.Minute {
+		return
+	}
+	t.lastPrune = now
+
+	for key, f := range t.entries {
+		if now.After(f.lockedUntil) && now.Sub(f.last) > t.lockout {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newLoginThrottle(3, 6, 15*time.Minute)
+	throttle.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, throttle.fail("a@example.com"), false)
+	}
+	assert.Equal(t, throttle.wait("a@example.com"), time.Duration(0))
+
+	// backoff starts at the third failure and doubles from there
+	throttle.fail("a@example.com")
+	assert.Equal(t, throttle.wait("a@example.com"), time.Second)
+	throttle.fail("a@example.com")
+	assert.Equal(t, throttle.wait("a@example.com"), 2*time.Second)
+	throttle.fail("a@example.com")
+	assert.Equal(t, throttle.wait("a@example.com"), 4*time.Second)
+
+	now = now.Add(3 * time.Second)
+	assert.Equal(t, throttle.wait("a@example.com"), time.Second)
+
+	// other keys are unaffected
+	assert.Equal(t, throttle.wait("b@example.com"), time.Duration(0))
+
+	// the sixth failure locks the key out
+	assert.Equal(t, throttle.fail("a@example.com"), true)
+	assert.Equal(t, throttle.wait("a@example.com"), 15*time.Minute)
+
+	now = now.Add(15 * time.Minute)
+	assert.Equal(t, throttle.wait("a@example.com"), time.Duration(0))
+
+	throttle.fail("a@example.com")
+	throttle.reset("a@example.com")
+	assert.Equal(t, throttle.wait("a@example.com"), time.Duration(0))
+
+	now = now.Add(time.Hour)
+	throttle.fail("b@example.com")
+	now = now.Add(time.Hour)
+	throttle.prune()
+	assert.Equal(t, len(throttle.entries), 0)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"caniteySnippetBox/internal/models"
+	"caniteySnippetBox/internal/validator"
+	"crypto/subtle"
+	"errors"
+	"image/png"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+// twoFactorLoginTimeout is how long the second login step may take after the
+// password has been checked.
+const twoFactorLoginTimeout = 5 * time.Minute
+
+const recoveryCodeCount = 10
+
+// totpPeriod is the length in seconds of a TOTP time step, the default of
+// totp.Generate.
+const totpPeriod = 30
+
+// totpStep returns the time step the code belongs to, accepting the steps
+// next to the current one for clock drift like totp.Validate does.
+func totpStep(code, secret string, now time.Time) (int64, bool) {
+	opts := totp.ValidateOpts{Period: totpPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	current := now.Unix() / totpPeriod
+	for _, step := range []int64{current - 1, current, current + 1} {
+		want, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0).UTC(), opts)
+		if err == nil && subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+type twoFactorEnableForm struct {
+	Code string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+type twoFactorPasswordForm struct {
+	Password string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type userLoginTwoFactorForm struct {
+	Code string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+func (a *application) startTwoFactorLogin(r *http.Request, id int) error {
+	if err := a.sessionManager.RenewToken(r.Context()); err != nil {
+		return err
+	}
+
+	a.sessionManager.Put(r.Context(), "twoFactorUserID", id)
+	a.sessionManager.Put(r.Context(), "twoFactorStarted", time.Now().Unix())
+
+	return nil
+}
+
+// pendingTwoFactorUserID returns the id of the user who passed the password
+// step of the login, or 0 if there is none or it took too long.
+func (a *application) pendingTwoFactorUserID(r *http.Request) int {
+	started := time.Unix(a.sessionManager.GetInt64(r.Context(), "twoFactorStarted"), 0)
+	if time.Since(started) > twoFactorLoginTimeout {
+		return 0
+	}
+	return a.sessionManager.GetInt(r.Context(), "twoFactorUserID")
+}
+
+func (a *application) userLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if a.pendingTwoFactorUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Form = userLoginTwoFactorForm{}
+	a.render(w, http.StatusOK, "login2fa.tmpl", data)
+}
+
+func (a *application) userLoginTwoFactorPost(w http.ResponseWriter, r *http.Request) {
+	id := a.pendingTwoFactorUserID(r)
+	if id == 0 {
+		a.sessionManager.Put(r.Context(), "flash", "Your login timed out, please try again")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form userLoginTwoFactorForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "this field can't be empty")
+
+	ip := a.clientIP(r)
+	account := "2fa:" + strconv.Itoa(id)
+	if wait := a.loginAccountThrottle.wait(account); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.AddNonFieldError("Too many failed login attempts, please try again later")
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusTooManyRequests, "login2fa.tmpl", data)
+		return
+	}
+
+	if form.Valid() {
+		ok, err := a.checkSecondFactor(id, form.Code)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		if !ok {
+			if err := a.recordLoginFailure(r, ip, account, id, "wrong two-factor code"); err != nil {
+				a.serverError(w, err)
+				return
+			}
+			form.AddNonFieldError("The code is incorrect")
+		}
+	}
+
+	if !form.Valid() {
+		data := a.newTemplateData(r)
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "login2fa.tmpl", data)
+		return
+	}
+
+	a.loginAccountThrottle.reset(account)
+	a.sessionManager.Remove(r.Context(), "twoFactorUserID")
+	a.sessionManager.Remove(r.Context(), "twoFactorStarted")
+
+	if err := a.logIn(r, id, "password and two-factor code"); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// checkSecondFactor accepts either a current TOTP code that wasn't used yet or
+// one of the unused recovery codes of the user.
+func (a *application) checkSecondFactor(id int, code string) (bool, error) {
+	secret, err := a.users.TOTPSecret(id)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := totpStep(code, secret, time.Now()); ok {
+		return a.users.UseTOTPStep(id, step)
+	}
+
+	return a.recoveryCodes.Use(id, code)
+}
+
+func (a *application) accountTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.User = user
+
+	if user.TOTPEnabled {
+		data.RecoveryCodesLeft, err = a.recoveryCodes.Remaining(id)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		data.Form = twoFactorPasswordForm{}
+		a.render(w, http.StatusOK, "twofactor.tmpl", data)
+		return
+	}
+
+	// a new secret is generated on every visit until one is confirmed
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer: "Snippetbox",
+		AccountName: user.Email,
+	})
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+	a.sessionManager.Put(r.Context(), "totpPendingURL", key.URL())
+
+	data.TOTPSecret = key.Secret()
+	data.Form = twoFactorEnableForm{}
+	a.render(w, http.StatusOK, "twofactor.tmpl", data)
+}
+
+func (a *application) pendingTOTPKey(r *http.Request) (*otp.Key, error) {
+	url := a.sessionManager.GetString(r.Context(), "totpPendingURL")
+	if url == "" {
+		return nil, models.ErrNoRecord
+	}
+	return otp.NewKeyFromURL(url)
+}
+
+func (a *application) accountTwoFactorQR(w http.ResponseWriter, r *http.Request) {
+	key, err := a.pendingTOTPKey(r)
+	if err != nil {
+		a.notFound(w)
+		return
+	}
+
+	img, err := key.Image(240, 240)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := png.Encode(w, img); err != nil {
+		a.errorLog.Print(err)
+	}
+}
+
+func (a *application) accountTwoFactorEnablePost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+
+	var form twoFactorEnableForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	key, err := a.pendingTOTPKey(r)
+	if err != nil {
+		http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+		return
+	}
+
+	step, ok := totpStep(form.Code, key.Secret(), time.Now())
+	form.CheckField(validator.NotBlank(form.Code), "code", "this field can't be empty")
+	form.CheckField(ok, "code", "The code is incorrect, check the clock of your device")
+
+	if !form.Valid() {
+		user, err := a.users.Get(id)
+		if err != nil {
+			a.serverError(w, err)
+			return
+		}
+		data := a.newTemplateData(r)
+		data.User = user
+		data.TOTPSecret = key.Secret()
+		data.Form = form
+		a.render(w, http.StatusUnprocessableEntity, "twofactor.tmpl", data)
+		return
+	}
+
+	if err = a.users.SetTOTPSecret(id, key.Secret()); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	// the code that confirmed the secret can't log in afterwards
+	if _, err = a.users.UseTOTPStep(id, step); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	a.sessionManager.Remove(r.Context(), "totpPendingURL")
+
+	a.renderRecoveryCodes(w, r, id, "Two-factor authentication is enabled")
+}
+
+func (a *application) accountTwoFactorRecoveryPost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	if !a.checkTwoFactorPassword(w, r, id) {
+		return
+	}
+
+	a.renderRecoveryCodes(w, r, id, "New recovery codes have been generated, the old ones no longer work")
+}
+
+func (a *application) accountTwoFactorDisablePost(w http.ResponseWriter, r *http.Request) {
+	id := a.sessionManager.GetInt(r.Context(), "id")
+	if !a.checkTwoFactorPassword(w, r, id) {
+		return
+	}
+
+	if err := a.users.SetTOTPSecret(id, ""); err != nil {
+		a.serverError(w, err)
+		return
+	}
+	if err := a.recoveryCodes.DeleteAll(id); err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	a.sessionManager.Put(r.Context(), "flash", "Two-factor authentication is disabled")
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
+
+// checkTwoFactorPassword confirms the password before a change to the two
+// factor settings, rendering the form with an error if it is wrong.
+func (a *application) checkTwoFactorPassword(w http.ResponseWriter, r *http.Request, id int) bool {
+	var form twoFactorPasswordForm
+	if err := a.decodePostForm(r, &form); err != nil {
+		a.clientError(w, http.StatusBadRequest)
+		return false
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "field must not be empty")
+	if form.Valid() {
+		err := a.users.CheckPassword(id, form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				a.serverError(w, err)
+				return false
+			}
+			form.AddFieldError("password", "Password is wrong")
+		}
+	}
+
+	if form.Valid() {
+		return true
+	}
+
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return false
+	}
+	data := a.newTemplateData(r)
+	data.User = user
+	data.RecoveryCodesLeft, err = a.recoveryCodes.Remaining(id)
+	if err != nil {
+		a.serverError(w, err)
+		return false
+	}
+	data.Form = form
+	a.render(w, http.StatusUnprocessableEntity, "twofactor.tmpl", data)
+	return false
+}
+
+func (a *application) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, id int, flash string) {
+	codes, err := a.recoveryCodes.Generate(id, recoveryCodeCount)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	user, err := a.users.Get(id)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Flash = flash
+	data.User = user
+	data.RecoveryCodes = codes
+	data.RecoveryCodesLeft = len(codes)
+	data.Form = twoFactorPasswordForm{}
+	a.render(w, http.StatusOK, "twofactor.tmpl", data)
+}
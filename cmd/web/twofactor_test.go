@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestTOTPStep(t *testing.T) {
+	secret := "JBSWY3DPEHPK3PXP"
+	now := time.Date(2024, 1, 1, 12, 0, 15, 0, time.UTC)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name string
+		at time.Time
+		wantStep int64
+		wantOK bool
+	}{
+		{name: "Current", at: now, wantStep: current, wantOK: true},
+		{name: "Previous", at: now.Add(-30 * time.Second), wantStep: current - 1, wantOK: true},
+		{name: "Next", at: now.Add(30 * time.Second), wantStep: current + 1, wantOK: true},
+		{name: "Too old", at: now.Add(-90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := totp.GenerateCode(secret, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			step, ok := totpStep(code, secret, now)
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, step, tt.wantStep)
+		})
+	}
+}
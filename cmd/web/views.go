@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"caniteySnippetBox/internal/models"
+)
+
+// viewStatsDays is how many days the stats page of a snippet shows.
+const viewStatsDays = 30
+
+// botAgents are parts of the user agents of crawlers, link previews and
+// scripts, whose visits aren't counted as views.
+var botAgents = []string{"bot", "crawl", "spider", "slurp", "facebookexternalhit", "embedly", "preview", "headless", "curl", "wget", "python", "go-http-client", "java/", "okhttp"}
+
+func isBot(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	if userAgent == "" {
+		return true
+	}
+
+	for _, agent := range botAgents {
+		if strings.Contains(userAgent, agent) {
+			return true
+		}
+	}
+	return false
+}
+
+// viewCounter counts snippet views in memory until they are flushed to the
+// database. A viewer seeing the same snippet again within window only counts
+// once.
+type viewCounter struct {
+	mu sync.Mutex
+	counts map[time.Time]map[int]int
+	seen map[string]time.Time
+	now func() time.Time
+	window time.Duration
+}
+
+func newViewCounter(window time.Duration) *viewCounter {
+	return &viewCounter{
+		counts: make(map[time.Time]map[int]int),
+		seen: make(map[string]time.Time),
+		now: time.Now,
+		window: window,
+	}
+}
+
+// count records a view of the snippet by viewer, reporting whether it counted.
+func (c *viewCounter) count(viewer string, snippetID int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	key := viewer + "/" + strconv.Itoa(snippetID)
+	if last, ok := c.seen[key]; ok && now.Sub(last) < c.window {
+		return false
+	}
+	c.seen[key] = now
+
+	day := now.UTC().Truncate(24 * time.Hour)
+	if c.counts[day] == nil {
+		c.counts[day] = make(map[int]int)
+	}
+	c.counts[day][snippetID]++
+	return true
+}
+
+// take hands over the views counted since the last call, by day, and forgets
+// the viewers whose window is over.
+func (c *viewCounter) take() map[time.Time]map[int]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for key, last := range c.seen {
+		if now.Sub(last) >= c.window {
+			delete(c.seen, key)
+		}
+	}
+
+	counts := c.counts
+	c.counts = make(map[time.Time]map[int]int)
+	return counts
+}
+
+// restore puts back views that couldn't be saved, for the next flush.
+func (c *viewCounter) restore(day time.Time, counts map[int]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts[day] == nil {
+		c.counts[day] = make(map[int]int)
+	}
+	for id, n := range counts {
+		c.counts[day][id] += n
+	}
+}
+
+// countView counts the request as a view of the snippet, unless it comes from
+// a bot or the owner. Viewers are told apart by their session, or by address
+// and browser before they have one.
+func (a *application) countView(r *http.Request, snippet *models.Snippet) {
+	if isBot(r.UserAgent()) || (a.IsAuthenticated(r) && snippet.UserID == a.sessionManager.GetInt(r.Context(), "id")) {
+		return
+	}
+
+	viewer := a.sessionManager.Token(r.Context())
+	if viewer == "" {
+		viewer = a.clientIP(r) + " " + r.UserAgent()
+	}
+	a.viewCounter.count(viewer, snippet.ID)
+}
+
+// flushViews saves the views counted in memory. What fails to save is kept
+// for the next try.
+func (a *application) flushViews() {
+	for day, counts := range a.viewCounter.take() {
+		if err := a.views.Add(day, counts); err != nil {
+			a.errorLog.Print(err)
+			a.viewCounter.restore(day, counts)
+		}
+	}
+}
+
+// flushViewsEvery saves the counted views every interval until ctx is done,
+// and then once more so nothing counted is lost.
+func (a *application) flushViewsEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.flushViews()
+		case <-ctx.Done():
+			a.flushViews()
+			return
+		}
+	}
+}
+
+// viewBar is a day of the views graph, sized for a 300x100 viewBox.
+type viewBar struct {
+	Day time.Time
+	Views int
+	X int
+	Y int
+	Width int
+	Height int
+}
+
+func viewBars(daily []*models.DailyViews) []viewBar {
+	most := 0
+	for _, d := range daily {
+		most = max(most, d.Views)
+	}
+
+	width := 300 / max(len(daily), 1)
+	bars := make([]viewBar, len(daily))
+	for i, d := range daily {
+		height := 0
+		if most > 0 {
+			height = d.Views * 100 / most
+		}
+		bars[i] = viewBar{Day: d.Day, Views: d.Views, X: i * width, Y: 100 - height, Width: max(width-2, 1), Height: height}
+	}
+	return bars
+}
+
+func (a *application) snippetStats(w http.ResponseWriter, r *http.Request) {
+	id, ok := idParam(r)
+	if !ok {
+		a.notFound(w)
+		return
+	}
+
+	snippet, err := a.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			a.notFound(w)
+		} else {
+			a.serverError(w, err)
+		}
+		return
+	}
+
+	if snippet.UserID != a.sessionManager.GetInt(r.Context(), "id") {
+		a.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	daily, err := a.views.Daily(id, viewStatsDays)
+	if err != nil {
+		a.serverError(w, err)
+		return
+	}
+
+	data := a.newTemplateData(r)
+	data.Snippet = snippet
+	data.ViewBars = viewBars(daily)
+	for _, d := range daily {
+		data.RecentViews += d.Views
+	}
+	a.render(w, http.StatusOK, "snippet_stats.tmpl", data)
+}
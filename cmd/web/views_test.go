@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+	"caniteySnippetBox/internal/models"
+)
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		name string
+		userAgent string
+		want bool
+	}{
+		{name: "Browser", userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:130.0) Gecko/20100101 Firefox/130.0", want: false},
+		{name: "Crawler", userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", want: true},
+		{name: "Link preview", userAgent: "facebookexternalhit/1.1", want: true},
+		{name: "Script", userAgent: "curl/8.5.0", want: true},
+		{name: "Empty", userAgent: "", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, isBot(tt.userAgent), tt.want)
+		})
+	}
+}
+
+func TestViewCounter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 50, 0, 0, time.UTC)
+	counter := newViewCounter(30 * time.Minute)
+	counter.now = func() time.Time { return now }
+
+	assert.Equal(t, counter.count("a", 1), true)
+	assert.Equal(t, counter.count("a", 1), false)
+	assert.Equal(t, counter.count("a", 2), true)
+	assert.Equal(t, counter.count("b", 1), true)
+
+	// the window is over, and it is the next day now
+	now = now.Add(30 * time.Minute)
+	assert.Equal(t, counter.count("a", 1), true)
+
+	counts := counter.take()
+	assert.Equal(t, len(counts), 2)
+	assert.Equal(t, counts[time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)][1], 2)
+	assert.Equal(t, counts[time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)][2], 1)
+	assert.Equal(t, counts[time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)][1], 1)
+	assert.Equal(t, len(counter.take()), 0)
+
+	// views that failed to save come back with the next ones
+	counter.restore(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), map[int]int{1: 1})
+	counter.count("b", 1)
+	assert.Equal(t, counter.take()[time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)][1], 2)
+}
+
+func TestViewBars(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := viewBars([]*models.DailyViews{{Day: day, Views: 2}, {Day: day.AddDate(0, 0, 1), Views: 4}, {Day: day.AddDate(0, 0, 2)}})
+
+	assert.Equal(t, bars[0].X, 0)
+	assert.Equal(t, bars[0].Height, 50)
+	assert.Equal(t, bars[0].Y, 50)
+	assert.Equal(t, bars[1].X, 100)
+	assert.Equal(t, bars[1].Height, 100)
+	assert.Equal(t, bars[2].Height, 0)
+}
@@ -0,0 +1,234 @@
+// Package highlight marks up source code for syntax highlighting. It is a
+// plain lexer that knows the comments, strings and keywords of the languages
+// snippets can be written in, and wraps them in spans with classes, so the
+// colours come from the stylesheet.
+package highlight
+
+import (
+	"html/template"
+	"strings"
+	"unicode"
+)
+
+// The classes put on the spans.
+const (
+	classComment = "hl-c"
+	classString = "hl-s"
+	classNumber = "hl-n"
+	classKeyword = "hl-k"
+)
+
+type syntax struct {
+	lineComments []string
+	blockComments [][2]string
+	quotes string
+	keywords map[string]bool
+	foldCase bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var languages = map[string]*syntax{
+	"go": {
+		lineComments: []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: "\"'`",
+		keywords: words(`break case chan const continue default defer else fallthrough for func go goto if import
+			interface map package range return select struct switch type var nil true false iota`),
+	},
+	"python": {
+		lineComments: []string{"#"},
+		quotes: "\"'",
+		keywords: words(`and as assert async await break class continue def del elif else except finally for from
+			global if import in is lambda nonlocal not or pass raise return try while with yield None True False`),
+	},
+	"javascript": {
+		lineComments: []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: "\"'`",
+		keywords: words(`async await break case catch class const continue debugger default delete do else export
+			extends finally for function if import in instanceof let new of return super switch this throw try
+			typeof var void while yield null undefined true false`),
+	},
+	"shell": {
+		lineComments: []string{"#"},
+		quotes: "\"'",
+		keywords: words(`if then else elif fi case esac for while until do done in function return local export`),
+	},
+	"sql": {
+		lineComments: []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: "'\"`",
+		foldCase: true,
+		keywords: words(`select from where and or not insert into values update set delete create table alter
+			drop index primary key foreign references on join left right inner outer group by order having
+			limit offset as distinct null is in like between case when then else end default constraint unique`),
+	},
+	"json": {
+		quotes: "\"",
+		keywords: words(`true false null`),
+	},
+	"yaml": {
+		lineComments: []string{"#"},
+		quotes: "\"'",
+		keywords: words(`true false null yes no`),
+	},
+	"html": {
+		blockComments: [][2]string{{"<!--", "-->"}},
+		quotes: "\"'",
+	},
+	"css": {
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes: "\"'",
+	},
+}
+
+// aliases are the other names code fences use for the languages.
+var aliases = map[string]string{
+	"golang": "go",
+	"py": "python",
+	"js": "javascript",
+	"sh": "shell",
+	"bash": "shell",
+	"yml": "yaml",
+}
+
+// Language returns the name the language is known by, or "" if it isn't.
+func Language(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	if _, ok := languages[name]; ok {
+		return name
+	}
+	return ""
+}
+
+// Code returns the code as HTML, highlighted if the language is known and
+// only escaped otherwise.
+func Code(language, code string) template.HTML {
+	syn := languages[Language(language)]
+	if syn == nil {
+		return template.HTML(template.HTMLEscapeString(code))
+	}
+
+	var b strings.Builder
+	for len(code) > 0 {
+		n, class := syn.next(code)
+		if class == "" {
+			b.WriteString(template.HTMLEscapeString(code[:n]))
+		} else {
+			b.WriteString(`<span class="` + class + `">`)
+			b.WriteString(template.HTMLEscapeString(code[:n]))
+			b.WriteString(`</span>`)
+		}
+		code = code[n:]
+	}
+
+	return template.HTML(b.String())
+}
+
+// next returns the length of the token at the start of s and its class.
+// Tokens that get no class are as long as possible, up to the next one that
+// might.
+func (syn *syntax) next(s string) (int, string) {
+	for _, prefix := range syn.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			if i := strings.IndexByte(s, '\n'); i >= 0 {
+				return i, classComment
+			}
+			return len(s), classComment
+		}
+	}
+
+	for _, delims := range syn.blockComments {
+		if strings.HasPrefix(s, delims[0]) {
+			if i := strings.Index(s[len(delims[0]):], delims[1]); i >= 0 {
+				return len(delims[0]) + i + len(delims[1]), classComment
+			}
+			return len(s), classComment
+		}
+	}
+
+	if strings.IndexByte(syn.quotes, s[0]) >= 0 {
+		return quoted(s), classString
+	}
+
+	r := rune(s[0])
+	if unicode.IsDigit(r) {
+		return wordLength(s), classNumber
+	}
+
+	if isWordStart(r) {
+		n := wordLength(s)
+		word := s[:n]
+		if syn.foldCase {
+			word = strings.ToLower(word)
+		}
+		if syn.keywords[word] {
+			return n, classKeyword
+		}
+		return n, ""
+	}
+
+	// anything else up to where a token could start
+	n := 1
+	for n < len(s) && !syn.tokenStart(s[n:]) {
+		n++
+	}
+	return n, ""
+}
+
+func (syn *syntax) tokenStart(s string) bool {
+	c := s[0]
+	if isWordStart(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte(syn.quotes, c) >= 0 {
+		return true
+	}
+	for _, prefix := range syn.lineComments {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	for _, delims := range syn.blockComments {
+		if strings.HasPrefix(s, delims[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// quoted returns the length of the string starting at s, up to the closing
+// quote, the end of the line for quotes other than backticks, or the end.
+func quoted(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+func wordLength(s string) int {
+	n := 0
+	for n < len(s) && (isWordStart(rune(s[n])) || s[n] >= '0' && s[n] <= '9' || s[n] == '.' && unicode.IsDigit(rune(s[0]))) {
+		n++
+	}
+	return n
+}
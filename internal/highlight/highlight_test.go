@@ -0,0 +1,53 @@
+package highlight
+
+import (
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		language string
+		code string
+		want string
+	}{
+		{
+			name: "Unknown language",
+			language: "cobol",
+			code: "<b>",
+			want: "&lt;b&gt;",
+		},
+		{
+			name: "Go",
+			language: "go",
+			code: "func f() { return \"<x>\" } // done",
+			want: `<span class="hl-k">func</span> f() { <span class="hl-k">return</span> <span class="hl-s">&#34;&lt;x&gt;&#34;</span> } <span class="hl-c">// done</span>`,
+		},
+		{
+			name: "Alias and numbers",
+			language: "py",
+			code: "x = 1.5 # one",
+			want: `x = <span class="hl-n">1.5</span> <span class="hl-c"># one</span>`,
+		},
+		{
+			name: "SQL keywords ignore case",
+			language: "sql",
+			code: "SELECT id",
+			want: `<span class="hl-k">SELECT</span> id`,
+		},
+		{
+			name: "Unterminated string stops at the line",
+			language: "javascript",
+			code: "'abc\nlet",
+			want: "<span class=\"hl-s\">&#39;abc</span>\n<span class=\"hl-k\">let</span>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(Code(tt.language, tt.code)), tt.want)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package mailer sends the transactional emails of the application.
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer delivers a plain text email to a single recipient.
+type Mailer interface {
+	Send(recipient, subject, body string) error
+}
+
+// message builds an RFC 5322 message.
+func message(sender, recipient, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", sender)
+	fmt.Fprintf(&b, "To: %s\r\n", recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+type SMTPMailer struct {
+	Host string
+	Port int
+	Username string
+	Password string
+	Sender string
+}
+
+func (m *SMTPMailer) Send(recipient, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.Sender, []string{recipient}, message(m.Sender, recipient, subject, body))
+}
+
+// FileMailer writes every email to its own file in Dir, for development.
+type FileMailer struct {
+	Dir string
+	Sender string
+}
+
+func (m *FileMailer) Send(recipient, subject, body string) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(recipient, "/", "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), message(m.Sender, recipient, subject, body), 0o600)
+}
+
+// LogMailer prints every email to Logger, for development.
+type LogMailer struct {
+	Logger *log.Logger
+	Sender string
+}
+
+func (m *LogMailer) Send(recipient, subject, body string) error {
+	m.Logger.Printf("email to %s\n%s", recipient, message(m.Sender, recipient, subject, body))
+	return nil
+}
@@ -0,0 +1,206 @@
+// Package markdown renders user written Markdown to HTML that is safe to put
+// on a page. Raw HTML is never rendered, and the parsed document is cut down
+// to an allowlist of elements before rendering, so anything the policy
+// doesn't name is dropped or reduced to its text.
+package markdown
+
+import (
+	"bytes"
+	"caniteySnippetBox/internal/highlight"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// policy is the set of node kinds a document may keep.
+type policy map[ast.NodeKind]bool
+
+// commentPolicy is the Markdown-lite of comments: paragraphs, lists, quotes,
+// code, emphasis and links.
+var commentPolicy = policy{
+	ast.KindDocument: true,
+	ast.KindParagraph: true,
+	ast.KindTextBlock: true,
+	ast.KindList: true,
+	ast.KindListItem: true,
+	ast.KindBlockquote: true,
+	ast.KindCodeBlock: true,
+	ast.KindFencedCodeBlock: true,
+	ast.KindText: true,
+	ast.KindString: true,
+	ast.KindEmphasis: true,
+	ast.KindCodeSpan: true,
+	ast.KindLink: true,
+	ast.KindAutoLink: true,
+}
+
+// documentPolicy is for Markdown snippets, which are whole documents with
+// headings and tables. Images are left out, the CSP wouldn't load them from
+// other sites anyway.
+var documentPolicy = policy{
+	ast.KindHeading: true,
+	ast.KindThematicBreak: true,
+	extast.KindTable: true,
+	extast.KindTableHeader: true,
+	extast.KindTableRow: true,
+	extast.KindTableCell: true,
+	extast.KindStrikethrough: true,
+}
+
+func init() {
+	for kind := range commentPolicy {
+		documentPolicy[kind] = true
+	}
+}
+
+var comments = goldmark.New(goldmark.WithRendererOptions(html.WithHardWraps()))
+
+var documents = goldmark.New(
+	goldmark.WithExtensions(
+		// alignments would need style attributes, which the CSP blocks
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignNone)),
+		extension.Strikethrough,
+		extension.Linkify,
+	),
+	goldmark.WithRendererOptions(renderer.WithNodeRenderers(util.Prioritized(codeRenderer{}, 100))),
+)
+
+// Comment renders a comment.
+func Comment(src string) template.HTML {
+	return render(comments, commentPolicy, src)
+}
+
+// Document renders a Markdown snippet, with its code fences highlighted.
+func Document(src string) template.HTML {
+	return render(documents, documentPolicy, src)
+}
+
+// codeRenderer renders code fences through the highlighter.
+type codeRenderer struct{}
+
+func (codeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderFencedCode)
+}
+
+func renderFencedCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*ast.FencedCodeBlock)
+	var code bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	// the info string is only trusted once the highlighter knows it
+	language := highlight.Language(string(n.Language(source)))
+	if language == "" {
+		w.WriteString("<pre><code>")
+	} else {
+		w.WriteString(`<pre><code class="language-` + language + `">`)
+	}
+	w.WriteString(string(highlight.Code(language, code.String())))
+	w.WriteString("</code></pre>\n")
+
+	return ast.WalkSkipChildren, nil
+}
+
+func render(md goldmark.Markdown, p policy, src string) template.HTML {
+	source := []byte(src)
+	doc := md.Parser().Parse(text.NewReader(source))
+	p.clean(doc, source)
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, source, doc); err != nil {
+		return template.HTML(template.HTMLEscapeString(src))
+	}
+
+	return template.HTML(buf.String())
+}
+
+// clean applies the policy to the children of n. Blocks that aren't allowed
+// become paragraphs if they hold text, or give their children to n; inline
+// nodes that aren't allowed are replaced by their children. Raw HTML is
+// dropped.
+func (p policy) clean(n ast.Node, source []byte) {
+	for c := n.FirstChild(); c != nil; {
+		next := c.NextSibling()
+
+		switch {
+		case c.Kind() == ast.KindHTMLBlock || c.Kind() == ast.KindRawHTML:
+			n.RemoveChild(n, c)
+		case c.Kind() == ast.KindLink && !safeURL(string(c.(*ast.Link).Destination)):
+			next = unwrap(n, c)
+		case c.Kind() == ast.KindAutoLink && !safeURL(string(c.(*ast.AutoLink).URL(source))):
+			n.ReplaceChild(n, c, ast.NewString(c.(*ast.AutoLink).Label(source)))
+		case !p[c.Kind()] && c.Type() == ast.TypeBlock && c.HasChildren() && c.FirstChild().Type() == ast.TypeInline:
+			para := ast.NewParagraph()
+			moveChildren(c, para)
+			n.ReplaceChild(n, c, para)
+			next = para
+		case !p[c.Kind()] && c.HasChildren():
+			next = unwrap(n, c)
+		case !p[c.Kind()]:
+			n.RemoveChild(n, c)
+		default:
+			c.RemoveAttributes()
+			p.clean(c, source)
+		}
+
+		c = next
+	}
+}
+
+// unwrap puts the children of c in its place and returns the first of them,
+// so they get cleaned too.
+func unwrap(parent, c ast.Node) ast.Node {
+	first := c.FirstChild()
+	for child := first; child != nil; {
+		next := child.NextSibling()
+		parent.InsertBefore(parent, c, child)
+		child = next
+	}
+	next := c.NextSibling()
+	parent.RemoveChild(parent, c)
+	if first != nil {
+		return first
+	}
+	return next
+}
+
+func moveChildren(from, to ast.Node) {
+	for child := from.FirstChild(); child != nil; {
+		next := child.NextSibling()
+		to.AppendChild(to, child)
+		child = next
+	}
+}
+
+// safeURL allows web and mail links and links within the site.
+func safeURL(s string) bool {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	case "":
+		// "//host" is another site with whatever scheme the page has
+		return u.Host == "" && !strings.HasPrefix(s, "//")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+func TestComment(t *testing.T) {
+	tests := []struct {
+		name string
+		src string
+		want string
+	}{
+		{
+			name: "Emphasis and code",
+			src: "use *this* and `x := 1`",
+			want: "<p>use <em>this</em> and <code>x := 1</code></p>\n",
+		},
+		{
+			name: "Raw HTML",
+			src: "hi <script>alert(1)</script> there",
+			want: "<p>hi alert(1) there</p>\n",
+		},
+		{
+			name: "HTML block",
+			src: "<div onclick='x()'>\nboo\n</div>",
+			want: "",
+		},
+		{
+			name: "Script link",
+			src: "[click](javascript:alert(1))",
+			want: "<p>click</p>\n",
+		},
+		{
+			name: "Web link",
+			src: "[docs](https://go.dev/doc)",
+			want: "<p><a href=\"https://go.dev/doc\">docs</a></p>\n",
+		},
+		{
+			name: "Heading",
+			src: "# Big",
+			want: "<p>Big</p>\n",
+		},
+		{
+			name: "Image",
+			src: "![alt text](https://example.com/x.png)",
+			want: "<p>alt text</p>\n",
+		},
+		{
+			name: "Escaped code block",
+			src: "```\n<b>&</b>\n```",
+			want: "<pre><code>&lt;b&gt;&amp;&lt;/b&gt;\n</code></pre>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Comment(tt.src))
+			assert.Equal(t, got, tt.want)
+			assert.Equal(t, strings.Contains(got, "<script"), false)
+		})
+	}
+}
+
+func TestDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		src string
+		want string
+	}{
+		{
+			name: "Heading",
+			src: "# Runbook",
+			want: "<h1>Runbook</h1>\n",
+		},
+		{
+			name: "Highlighted fence",
+			src: "```go\nreturn nil\n```",
+			want: "<pre><code class=\"language-go\"><span class=\"hl-k\">return</span> <span class=\"hl-k\">nil</span>\n</code></pre>\n",
+		},
+		{
+			name: "Unknown fence language",
+			src: "```\"><script>\n<b>\n```",
+			want: "<pre><code>&lt;b&gt;\n</code></pre>\n",
+		},
+		{
+			name: "Aligned table",
+			src: "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			want: "<table>\n<thead>\n<tr>\n<th>a</th>\n<th>b</th>\n</tr>\n</thead>\n<tbody>\n<tr>\n<td>1</td>\n<td>2</td>\n</tr>\n</tbody>\n</table>\n",
+		},
+		{
+			name: "Image",
+			src: "![diagram](https://example.com/d.png)",
+			want: "<p>diagram</p>\n",
+		},
+		{
+			name: "Raw HTML",
+			src: "<img src=x onerror=alert(1)>",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(Document(tt.src)), tt.want)
+		})
+	}
+}
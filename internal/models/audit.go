@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	AuditSignup = "user.signup"
+	AuditLoginSuccess = "login.success"
+	AuditLoginFailure = "login.failure"
+	AuditLoginLockout = "login.lockout"
+	AuditLogout = "user.logout"
+	AuditPasswordChange = "password.change"
+	AuditPasswordReset = "password.reset"
+	AuditSnippetCreate = "snippet.create"
+	AuditSnippetDelete = "snippet.delete"
+	AuditSnippetFork = "snippet.fork"
+	AuditSnippetReport = "snippet.report"
+	AuditSnippetAutoHide = "snippet.autohide"
+	AuditAdminUserDisable = "admin.user.disable"
+	AuditAdminUserEnable = "admin.user.enable"
+	AuditAdminSnippetDelete = "admin.snippet.delete"
+	AuditAdminSnippetHide = "admin.snippet.hide"
+	AuditAdminReportDismiss = "admin.report.dismiss"
+	AuditAdminCommentRemove = "admin.comment.remove"
+)
+
+// AuditEntry records a security-relevant event. UserID is 0 when the event
+// can't be tied to an account.
+type AuditEntry struct {
+	ID int
+	UserID int
+	Event string
+	IP string
+	UserAgent string
+	Detail string
+	Created time.Time
+}
+
+type AuditModel struct {
+	DB *sql.DB
+}
+
+// Insert records an event. The user agent and detail can come from the client,
+// so they are cut to fit their columns.
+func (m *AuditModel) Insert(userID int, event, ip, userAgent, detail string) error {
+	stmt := `INSERT INTO audit_log (user_id, event, ip, user_agent, detail, created) VALUES (NULLIF(?, 0), ?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, event, ip, truncate(userAgent, 255), truncate(detail, 255))
+	return err
+}
+
+// ForUser returns a page of the entries about the user, newest first.
+func (m *AuditModel) ForUser(userID, limit, offset int) ([]*AuditEntry, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), event, ip, user_agent, detail, created FROM audit_log
+	WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`
+	return m.query(stmt, userID, limit, offset)
+}
+
+// All returns a page of all entries, newest first.
+func (m *AuditModel) All(limit, offset int) ([]*AuditEntry, error) {
+	stmt := `SELECT id, COALESCE(user_id, 0), event, ip, user_agent, detail, created FROM audit_log
+	ORDER BY id DESC LIMIT ? OFFSET ?`
+	return m.query(stmt, limit, offset)
+}
+
+func (m *AuditModel) query(stmt string, args ...any) ([]*AuditEntry, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		e := &AuditEntry{}
+		err := rows.Scan(&e.ID, &e.UserID, &e.Event, &e.IP, &e.UserAgent, &e.Detail, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// DeleteBefore removes the entries older than t and returns how many there
+// were.
+func (m *AuditModel) DeleteBefore(t time.Time) (int64, error) {
+	stmt := `DELETE FROM audit_log WHERE created < ?`
+	result, err := m.DB.Exec(stmt, t.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
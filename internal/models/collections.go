@@ -0,0 +1,166 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Collection is a named, ordered list of snippets put together by a user.
+type Collection struct {
+	ID int
+	UserID int
+	Name string
+	Private bool
+	Snippets int
+	Created time.Time
+}
+
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+func (m *CollectionModel) Insert(userID int, name string, private bool) (int, error) {
+	stmt := `INSERT INTO collections (user_id, name, private, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, userID, name, private)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (m *CollectionModel) Get(id int) (*Collection, error) {
+	stmt := `SELECT c.id, c.user_id, c.name, c.private, COUNT(cs.snippet_id), c.created
+	FROM collections c LEFT JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE c.id = ? GROUP BY c.id`
+
+	c := &Collection{}
+	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.UserID, &c.Name, &c.Private, &c.Snippets, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// ForUser returns the collections of the user in alphabetical order.
+func (m *CollectionModel) ForUser(userID int) ([]*Collection, error) {
+	stmt := `SELECT c.id, c.user_id, c.name, c.private, COUNT(cs.snippet_id), c.created
+	FROM collections c LEFT JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE c.user_id = ? GROUP BY c.id ORDER BY c.name`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		c := &Collection{}
+		err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Private, &c.Snippets, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+func (m *CollectionModel) Update(id int, name string, private bool) error {
+	stmt := `UPDATE collections SET name = ?, private = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, name, private, id)
+	return err
+}
+
+func (m *CollectionModel) Delete(id int) error {
+	stmt := `DELETE FROM collections WHERE id = ?`
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// Snippets returns the snippets in the collection in their order, leaving
+// out expired ones.
+func (m *CollectionModel) Snippets(id int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumnsOf("s") + `
+	FROM snippets s JOIN collection_snippets cs ON cs.snippet_id = s.id
+	WHERE cs.collection_id = ? AND s.expires > UTC_TIMESTAMP()
+	ORDER BY cs.position`
+	return (&SnippetModel{DB: m.DB}).query(stmt, id)
+}
+
+// AddSnippet appends the snippet to the collection. Adding a snippet that is
+// already in it does nothing.
+func (m *CollectionModel) AddSnippet(id, snippetID int) error {
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID, id)
+	return err
+}
+
+func (m *CollectionModel) RemoveSnippet(id, snippetID int) error {
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+	_, err := m.DB.Exec(stmt, id, snippetID)
+	return err
+}
+
+// MoveSnippet swaps the snippet with the one before it (up) or after it in
+// the collection. Moving past either end does nothing.
+func (m *CollectionModel) MoveSnippet(id, snippetID int, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+	stmt := `SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`
+	err = tx.QueryRow(stmt, id, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	stmt = `SELECT snippet_id, position FROM collection_snippets WHERE collection_id = ? AND position > ? ORDER BY position LIMIT 1 FOR UPDATE`
+	if up {
+		stmt = `SELECT snippet_id, position FROM collection_snippets WHERE collection_id = ? AND position < ? ORDER BY position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	var otherID, otherPosition int
+	err = tx.QueryRow(stmt, id, position).Scan(&otherID, &otherPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else {
+			return err
+		}
+	}
+
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+	if _, err := tx.Exec(stmt, otherPosition, id, snippetID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(stmt, position, id, otherID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
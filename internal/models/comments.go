@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Who took a comment down. Removed comments keep their place in the thread so
+// the replies still make sense, but lose their body.
+const (
+	CommentRemovedByAuthor = "author"
+	CommentRemovedByModerator = "moderator"
+)
+
+type Comment struct {
+	ID int
+	SnippetID int
+	UserID int
+	Author string
+	ParentID int
+	File string
+	Line int
+	Body string
+	Removed string
+	Created time.Time
+	Edited time.Time
+	Replies []*Comment
+}
+
+type CommentModel struct {
+	DB *sql.DB
+}
+
+const commentColumns = `c.id, c.snippet_id, COALESCE(c.user_id, 0), COALESCE(u.name, ''), COALESCE(c.parent_id, 0), c.file, c.line, c.body, c.removed, c.created, c.edited`
+
+func scanComment(row scanner) (*Comment, error) {
+	c := &Comment{}
+	var edited sql.NullTime
+	err := row.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.ParentID, &c.File, &c.Line, &c.Body, &c.Removed, &c.Created, &edited)
+	c.Edited = edited.Time
+	return c, err
+}
+
+// Insert adds a comment to the snippet, as a reply if parentID isn't 0. A line
+// of 0 means the comment is about the whole snippet.
+func (m *CommentModel) Insert(snippetID, userID, parentID int, file string, line int, body string) (int, error) {
+	var parent sql.NullInt64
+	if parentID != 0 {
+		parent = sql.NullInt64{Int64: int64(parentID), Valid: true}
+	}
+
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, file, line, body, created) VALUES (?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.Exec(stmt, snippetID, userID, parent, file, line, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// ForSnippet returns the threads of the snippet, oldest first, with the
+// replies of every comment in its Replies.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM comments c LEFT JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ? ORDER BY c.id`
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []*Comment{}
+	byID := map[int]*Comment{}
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[c.ID] = c
+
+		// replies always come after their parent, since ids only go up
+		if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			threads = append(threads, c)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return threads, nil
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	stmt := `UPDATE comments SET body = ?, edited = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := m.DB.Exec(stmt, body, id)
+	return err
+}
+
+// Remove takes the comment down, by is CommentRemovedByAuthor or
+// CommentRemovedByModerator.
+func (m *CommentModel) Remove(id int, by string) error {
+	stmt := `UPDATE comments SET body = '', removed = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, by, id)
+	return err
+}
@@ -8,4 +8,5 @@ var (
 	ErrNoRecord = errors.New("models: no matching record found")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+	ErrDuplicateReport = errors.New("models: duplicate report")
 )
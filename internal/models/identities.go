@@ -0,0 +1,33 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// IdentityModel links accounts at external OpenID Connect providers, known by
+// their issuer and subject, to users.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+func (m *IdentityModel) UserID(issuer, subject string) (int, error) {
+	var userID int
+	stmt := `SELECT user_id FROM identities WHERE issuer = ? AND subject = ?`
+	err := m.DB.QueryRow(stmt, issuer, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (m *IdentityModel) Insert(userID int, issuer, subject string) error {
+	stmt := `INSERT INTO identities (user_id, issuer, subject, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, issuer, subject)
+	return err
+}
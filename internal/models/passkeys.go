@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Passkey is a WebAuthn credential registered by a user. Credential holds the
+// JSON encoded credential as returned by the WebAuthn library, including its
+// public key and signature counter.
+type Passkey struct {
+	ID int
+	UserID int
+	Name string
+	CredentialID []byte
+	Credential []byte
+	Created time.Time
+	LastUsed sql.NullTime
+}
+
+type PasskeyModel struct {
+	DB *sql.DB
+}
+
+func (m *PasskeyModel) Insert(userID int, name string, credentialID, credential []byte) error {
+	stmt := `INSERT INTO passkeys (user_id, name, credential_id, credential, created) VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, userID, name, credentialID, credential)
+	return err
+}
+
+func (m *PasskeyModel) ForUser(userID int) ([]*Passkey, error) {
+	stmt := `SELECT id, user_id, name, credential_id, credential, created, last_used FROM passkeys WHERE user_id = ? ORDER BY id`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passkeys := []*Passkey{}
+
+	for rows.Next() {
+		p := &Passkey{}
+		err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.CredentialID, &p.Credential, &p.Created, &p.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return passkeys, nil
+}
+
+func (m *PasskeyModel) GetByCredentialID(credentialID []byte) (*Passkey, error) {
+	stmt := `SELECT id, user_id, name, credential_id, credential, created, last_used FROM passkeys WHERE credential_id = ?`
+
+	p := &Passkey{}
+	err := m.DB.QueryRow(stmt, credentialID).Scan(&p.ID, &p.UserID, &p.Name, &p.CredentialID, &p.Credential, &p.Created, &p.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Used stores the credential after a successful login, which carries the new
+// signature counter.
+func (m *PasskeyModel) Used(id int, credential []byte) error {
+	stmt := `UPDATE passkeys SET credential = ?, last_used = UTC_TIMESTAMP() WHERE id = ?`
+	_, err := m.DB.Exec(stmt, credential, id)
+	return err
+}
+
+func (m *PasskeyModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM passkeys WHERE id = ? AND user_id = ?`
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// RecoveryCodeModel manages the one-time codes that let a user log in when
+// they lost their authenticator. Codes have 80 random bits and are stored
+// hashed with bcrypt, so a copy of the database doesn't give them away.
+type RecoveryCodeModel struct {
+	DB *sql.DB
+}
+
+// normalizeRecoveryCode accepts codes typed in lower case or without dashes.
+func normalizeRecoveryCode(code string) []byte {
+	return []byte(strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
+
+// Generate replaces the recovery codes of the user with n new ones and
+// returns them in plaintext.
+func (m *RecoveryCodeModel) Generate(userID, n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := base32.StdEncoding.EncodeToString(b)
+		codes[i] = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:]
+	}
+
+	// the codes are random, so the default cost is plenty and keeps checking
+	// all of them quick
+	hashes := make([][]byte, n)
+	for i, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword(normalizeRecoveryCode(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err = deleteRecoveryCodes(tx, userID); err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO recovery_codes (user_id, hash, created) VALUES (?, ?, UTC_TIMESTAMP())`
+	for _, hash := range hashes {
+		if _, err = tx.Exec(stmt, userID, hash); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Use consumes code if it is one of the unused recovery codes of the user.
+func (m *RecoveryCodeModel) Use(userID int, code string) (bool, error) {
+	rows, err := m.DB.Query(`SELECT id, hash FROM recovery_codes WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	matched := 0
+	for rows.Next() {
+		var id int
+		var hash []byte
+		if err := rows.Scan(&id, &hash); err != nil {
+			return false, err
+		}
+
+		err := bcrypt.CompareHashAndPassword(hash, normalizeRecoveryCode(code))
+		if err == nil {
+			matched = id
+			break
+		}
+		if !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	rows.Close()
+
+	if matched == 0 {
+		return false, nil
+	}
+
+	// deleting decides which of two logins racing with the same code wins
+	result, err := m.DB.Exec(`DELETE FROM recovery_codes WHERE id = ?`, matched)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (m *RecoveryCodeModel) Remaining(userID int) (int, error) {
+	var n int
+	stmt := `SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?`
+	err := m.DB.QueryRow(stmt, userID).Scan(&n)
+	return n, err
+}
+
+func (m *RecoveryCodeModel) DeleteAll(userID int) error {
+	return deleteRecoveryCodes(m.DB, userID)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func deleteRecoveryCodes(db execer, userID int) error {
+	stmt := `DELETE FROM recovery_codes WHERE user_id = ?`
+	_, err := db.Exec(stmt, userID)
+	return err
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// ReportedSnippet sums up the open reports about a snippet for the
+// moderation queue.
+type ReportedSnippet struct {
+	SnippetID int
+	Title string
+	Hidden bool
+	Reports int
+	Reasons string
+	Details string
+	LastReported time.Time
+}
+
+type ReportModel struct {
+	DB *sql.DB
+}
+
+// Insert records that the user reported the snippet and returns how many
+// distinct users have open reports about it now. A user can only report a
+// snippet once, a second report gets ErrDuplicateReport.
+func (m *ReportModel) Insert(snippetID, userID int, reason, details string) (int, error) {
+	stmt := `INSERT INTO reports (snippet_id, user_id, reason, details, created) VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+	_, err := m.DB.Exec(stmt, snippetID, userID, reason, details)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "reports_uc_snippet_user") {
+				return 0, ErrDuplicateReport
+			}
+		}
+		return 0, err
+	}
+
+	var count int
+	stmt = `SELECT COUNT(DISTINCT user_id) FROM reports WHERE snippet_id = ? AND resolved = FALSE`
+	err = m.DB.QueryRow(stmt, snippetID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Queue lists the snippets with open reports, the most reported first.
+func (m *ReportModel) Queue() ([]*ReportedSnippet, error) {
+	stmt := `SELECT s.id, s.title, s.hidden, COUNT(*), GROUP_CONCAT(DISTINCT r.reason ORDER BY r.reason SEPARATOR ', '),
+	COALESCE(GROUP_CONCAT(NULLIF(r.details, '') ORDER BY r.id SEPARATOR ' / '), ''), MAX(r.created)
+	FROM reports r JOIN snippets s ON s.id = r.snippet_id
+	WHERE r.resolved = FALSE
+	GROUP BY s.id, s.title, s.hidden
+	ORDER BY COUNT(*) DESC, MAX(r.created)`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queue := []*ReportedSnippet{}
+
+	for rows.Next() {
+		rs := &ReportedSnippet{}
+		err := rows.Scan(&rs.SnippetID, &rs.Title, &rs.Hidden, &rs.Reports, &rs.Reasons, &rs.Details, &rs.LastReported)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, rs)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+// Decisions a moderator can take on a reported snippet.
+const (
+	ReportDismiss = "dismiss"
+	ReportHide = "hide"
+	ReportDelete = "delete"
+)
+
+// Resolve applies the moderator's decision to the snippet, putting it back up,
+// hiding it or deleting it, and closes its open reports in one transaction.
+// It returns ErrNoRecord if the snippet doesn't exist.
+func (m *ReportModel) Resolve(snippetID int, decision string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`SELECT id FROM snippets WHERE id = ? FOR UPDATE`, snippetID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	var stmt string
+	switch decision {
+	case ReportDismiss:
+		stmt = `UPDATE snippets SET hidden = FALSE WHERE id = ?`
+	case ReportHide:
+		stmt = `UPDATE snippets SET hidden = TRUE WHERE id = ?`
+	case ReportDelete:
+		stmt = `DELETE FROM snippets WHERE id = ?`
+	default:
+		return fmt.Errorf("models: unknown report decision %q", decision)
+	}
+	if _, err = tx.Exec(stmt, snippetID); err != nil {
+		return err
+	}
+
+	// deleting the snippet took its reports with it
+	if _, err = tx.Exec(`UPDATE reports SET resolved = TRUE WHERE snippet_id = ? AND resolved = FALSE`, snippetID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordResetModel manages the single-use tokens emailed by the forgotten
+// password flow. Like API tokens, only their SHA-256 hash is stored.
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+// New replaces any pending reset tokens of the user and returns the plaintext
+// of a new one.
+func (m *PasswordResetModel) New(userID int, ttl time.Duration) (string, error) {
+	token, err := generateToken(userID, ttl)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE FROM password_resets WHERE user_id = ?`
+	if _, err = tx.Exec(stmt, userID); err != nil {
+		return "", err
+	}
+
+	stmt = `INSERT INTO password_resets (hash, user_id, expiry, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	if _, err = tx.Exec(stmt, token.Hash, token.UserID, token.Expiry.UTC()); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return token.Plaintext, nil
+}
+
+// Valid reports whether plaintext is an unused, unexpired reset token.
+func (m *PasswordResetModel) Valid(plaintext string) (bool, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM password_resets WHERE hash = ? AND expiry > UTC_TIMESTAMP())`
+	err := m.DB.QueryRow(stmt, hash[:]).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// Reset sets the password of the user the token was issued to and burns all
+// of their reset tokens. It returns the id of that user.
+func (m *PasswordResetModel) Reset(plaintext, newPassword string) (int, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	stmt := `SELECT user_id FROM password_resets WHERE hash = ? AND expiry > UTC_TIMESTAMP() FOR UPDATE`
+	err = tx.QueryRow(stmt, hash[:]).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		} else {
+			return 0, err
+		}
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	// following the emailed link also proves the user owns the address
+	stmt = `UPDATE users SET hashed_password = ?, email_verified = TRUE WHERE id = ?`
+	if _, err = tx.Exec(stmt, newHashedPassword, userID); err != nil {
+		return 0, err
+	}
+
+	stmt = `DELETE FROM password_resets WHERE user_id = ?`
+	if _, err = tx.Exec(stmt, userID); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
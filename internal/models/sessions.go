@@ -0,0 +1,136 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserSession is what we know about a logged in session. The scs token is
+// never shown to users, sessions are referred to by ID instead.
+type UserSession struct {
+	ID int
+	Token string
+	UserID int
+	IP string
+	UserAgent string
+	Created time.Time
+	LastSeen time.Time
+}
+
+// UserSessionModel keeps metadata about the sessions in the scs sessions
+// table, so a user can see and revoke where they are logged in.
+type UserSessionModel struct {
+	DB *sql.DB
+}
+
+// Touch records that the session was used, creating the record for sessions
+// that started before they were tracked.
+func (m *UserSessionModel) Touch(token string, userID int, ip, userAgent string) error {
+	stmt := `INSERT INTO user_sessions (token, user_id, ip, user_agent, created, last_seen)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE ip = VALUES(ip), user_agent = VALUES(user_agent), last_seen = UTC_TIMESTAMP()`
+	_, err := m.DB.Exec(stmt, token, userID, ip, truncate(userAgent, 255))
+	return err
+}
+
+// ForUser lists the sessions of the user that are still alive in the session
+// store, most recently used first.
+func (m *UserSessionModel) ForUser(userID int) ([]*UserSession, error) {
+	stmt := `SELECT us.id, us.token, us.user_id, us.ip, us.user_agent, us.created, us.last_seen
+	FROM user_sessions us JOIN sessions s ON s.token = us.token
+	WHERE us.user_id = ? AND s.expiry > UTC_TIMESTAMP(6)
+	ORDER BY us.last_seen DESC`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*UserSession{}
+
+	for rows.Next() {
+		s := &UserSession{}
+		err := rows.Scan(&s.ID, &s.Token, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (m *UserSessionModel) Get(id, userID int) (*UserSession, error) {
+	stmt := `SELECT id, token, user_id, ip, user_agent, created, last_seen FROM user_sessions WHERE id = ? AND user_id = ?`
+
+	s := &UserSession{}
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&s.ID, &s.Token, &s.UserID, &s.IP, &s.UserAgent, &s.Created, &s.LastSeen)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (m *UserSessionModel) Delete(token string) error {
+	stmt := `DELETE FROM user_sessions WHERE token = ?`
+	_, err := m.DB.Exec(stmt, token)
+	return err
+}
+
+// Tokens returns the session tokens of the user except keep.
+func (m *UserSessionModel) Tokens(userID int, keep string) ([]string, error) {
+	stmt := `SELECT token FROM user_sessions WHERE user_id = ? AND token <> ?`
+	rows, err := m.DB.Query(stmt, userID, keep)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// DeleteForUser forgets all sessions of the user except the one with token
+// keep.
+func (m *UserSessionModel) DeleteForUser(userID int, keep string) error {
+	stmt := `DELETE FROM user_sessions WHERE user_id = ? AND token <> ?`
+	_, err := m.DB.Exec(stmt, userID, keep)
+	return err
+}
+
+// DeleteStale forgets sessions that are gone from the session store.
+func (m *UserSessionModel) DeleteStale() error {
+	stmt := `DELETE FROM user_sessions WHERE token NOT IN (SELECT token FROM sessions WHERE expiry > UTC_TIMESTAMP(6))`
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
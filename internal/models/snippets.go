@@ -3,24 +3,71 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
 type Snippet struct {
 	ID int
+	UserID int
 	Title string
+	Filename string
 	Content string
+	Language string
+	Hidden bool
+	Private bool
+	ParentID int
+	Stars int
+	Views int
 	Created time.Time
 	Expires time.Time
 }
 
+// SnippetFile is one of the files of a snippet. The first file is stored on the
+// snippet itself, so single file snippets read the same as they always did.
+type SnippetFile struct {
+	Name string
+	Language string
+	Content string
+}
+
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires) VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
-	result, err := m.DB.Exec(stmt, title, content, expires)
+// snippetColumns is what every query selects, in the order scanSnippet reads
+// them.
+const snippetColumns = `id, user_id, title, filename, content, language, hidden, private, parent_id, stars, views, created, expires`
+
+// snippetColumnsOf qualifies snippetColumns with a table alias, for joins.
+func snippetColumnsOf(alias string) string {
+	return alias + "." + strings.ReplaceAll(snippetColumns, ", ", ", "+alias+".")
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSnippet(row scanner) (*Snippet, error) {
+	s := &Snippet{}
+	var parentID sql.NullInt64
+	err := row.Scan(&s.ID, &s.UserID, &s.Title, &s.Filename, &s.Content, &s.Language, &s.Hidden, &s.Private, &parentID, &s.Stars, &s.Views, &s.Created, &s.Expires)
+	s.ParentID = int(parentID.Int64)
+	return s, err
+}
+
+// Insert creates a snippet with the files in order and the tags. There must be
+// at least one file.
+func (m *SnippetModel) Insert(userID int, title string, files []*SnippetFile, tags []string, expires int, private bool) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	first := files[0]
+	stmt := `INSERT INTO snippets (user_id, title, filename, content, language, private, created, expires) VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	result, err := tx.Exec(stmt, userID, title, first.Name, first.Content, first.Language, private, expires)
 	if err != nil {
 		return 0, err
 	}
@@ -30,15 +77,113 @@ func (m *SnippetModel) Insert(title, content string, expires int) (int, error) {
 		return 0, err
 	}
 
+	stmt = `INSERT INTO snippet_files (snippet_id, position, name, language, content) VALUES (?, ?, ?, ?, ?)`
+	for i, f := range files[1:] {
+		if _, err := tx.Exec(stmt, id, i+1, f.Name, f.Language, f.Content); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := insertTags(tx, int(id), tags); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
+// Fork copies the snippet with its files and tags to a new snippet owned by
+// the user, recording where it came from. The copy lives as long as the
+// original was meant to, counting from now.
+func (m *SnippetModel) Fork(id, userID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippets (user_id, title, filename, content, language, private, parent_id, created, expires)
+	SELECT ?, title, filename, content, language, private, id, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL DATEDIFF(expires, created) DAY)
+	FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	result, err := tx.Exec(stmt, userID, id)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, ErrNoRecord
+	}
+
+	forkID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+	SELECT ?, position, name, language, content FROM snippet_files WHERE snippet_id = ?`
+	if _, err := tx.Exec(stmt, forkID, id); err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT ?, tag_id FROM snippet_tags WHERE snippet_id = ?`
+	if _, err := tx.Exec(stmt, forkID, id); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(forkID), nil
+}
+
+// Forks counts the snippets forked from the snippet.
+func (m *SnippetModel) Forks(id int) (int, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets WHERE parent_id = ?`, id).Scan(&n)
+	return n, err
+}
+
+// Files returns every file of the snippet, starting with the one stored on it.
+func (m *SnippetModel) Files(s *Snippet) ([]*SnippetFile, error) {
+	files := []*SnippetFile{{Name: s.Filename, Language: s.Language, Content: s.Content}}
+
+	stmt := `SELECT name, language, content FROM snippet_files WHERE snippet_id = ? ORDER BY position`
+	rows, err := m.DB.Query(stmt, s.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f := &SnippetFile{}
+		if err := rows.Scan(&f.Name, &f.Language, &f.Content); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Get returns the snippet if it hasn't expired. Hidden and private snippets
+// are returned too, it's up to the caller who gets to see them.
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
-	row := m.DB.QueryRow(stmt, id)
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
 
-	s := &Snippet{}
-	if err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires); err != nil {
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
 		} else {
@@ -49,10 +194,53 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 	return s, nil
 }
 
-
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
-	rows, err := m.DB.Query(stmt)
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE expires > UTC_TIMESTAMP() AND hidden = FALSE AND private = FALSE ORDER BY id DESC LIMIT 10`
+	return m.query(stmt)
+}
+
+// MostStarred returns the public snippets with the most stars.
+func (m *SnippetModel) MostStarred() ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE expires > UTC_TIMESTAMP() AND hidden = FALSE AND private = FALSE AND stars > 0 ORDER BY stars DESC, id DESC LIMIT 10`
+	return m.query(stmt)
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE expires > UTC_TIMESTAMP() AND user_id = ? ORDER BY id DESC`
+	return m.query(stmt, userID)
+}
+
+// EachByUser calls fn with every snippet of the user, expired ones included,
+// reading them one row at a time.
+func (m *SnippetModel) EachByUser(userID int, fn func(*Snippet) error) error {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets WHERE user_id = ? ORDER BY id`
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// All returns a page of snippets, expired ones included, newest first.
+func (m *SnippetModel) All(limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets ORDER BY id DESC LIMIT ? OFFSET ?`
+	return m.query(stmt, limit, offset)
+}
+
+func (m *SnippetModel) query(stmt string, args ...any) ([]*Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -61,8 +249,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	snippets := []*Snippet{}
 
 	for rows.Next() {
-		s := &Snippet{}
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -75,3 +262,29 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	return snippets, nil
 }
+
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// SetHidden takes the snippet off the site, or puts it back, without deleting
+// it.
+func (m *SnippetModel) SetHidden(id int, hidden bool) error {
+	stmt := `UPDATE snippets SET hidden = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, hidden, id)
+	return err
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type StarModel struct {
+	DB *sql.DB
+}
+
+// Toggle stars the snippet for the user, or takes the star back if it was
+// already starred, keeping the count on the snippet in step. It reports
+// whether the snippet is starred now.
+func (m *StarModel) Toggle(userID, snippetID int) (bool, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES (?, ?, UTC_TIMESTAMP())`, userID, snippetID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	starred := n == 1
+	stmt := `UPDATE snippets SET stars = stars + 1 WHERE id = ?`
+	if !starred {
+		if _, err := tx.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID); err != nil {
+			return false, err
+		}
+		stmt = `UPDATE snippets SET stars = stars - 1 WHERE id = ? AND stars > 0`
+	}
+
+	if _, err := tx.Exec(stmt, snippetID); err != nil {
+		return false, err
+	}
+
+	return starred, tx.Commit()
+}
+
+func (m *StarModel) Has(userID, snippetID int) (bool, error) {
+	var exists bool
+	stmt := `SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)`
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&exists)
+	return exists, err
+}
+
+// Snippets returns a page of the snippets the user starred, most recently
+// starred first. Snippets that expired, or that were made private or hidden
+// by someone else since, are left out.
+func (m *StarModel) Snippets(userID, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumnsOf("s") + ` FROM snippets s JOIN stars st ON st.snippet_id = s.id
+	WHERE st.user_id = ? AND s.expires > UTC_TIMESTAMP() AND ((s.hidden = FALSE AND s.private = FALSE) OR s.user_id = st.user_id)
+	ORDER BY st.created DESC, s.id DESC LIMIT ? OFFSET ?`
+	return (&SnippetModel{DB: m.DB}).query(stmt, userID, limit, offset)
+}
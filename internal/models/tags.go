@@ -0,0 +1,113 @@
+package models
+
+import (
+	"database/sql"
+)
+
+// TagCount is a tag and how many visible snippets have it.
+type TagCount struct {
+	Name string
+	Count int
+}
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+// visibleSnippets is the condition for snippets that may be listed publicly.
+const visibleSnippets = `s.expires > UTC_TIMESTAMP() AND s.hidden = FALSE AND s.private = FALSE`
+
+// insertTags adds the tags to the snippet, creating the ones that don't exist
+// yet, so a new snippet gets its tags in the transaction that creates it.
+func insertTags(db execer, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		if _, err := db.Exec(`INSERT IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := db.Exec(stmt, snippetID, tag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForSnippet returns the tags of the snippet in alphabetical order.
+func (m *TagModel) ForSnippet(snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t JOIN snippet_tags st ON st.tag_id = t.id WHERE st.snippet_id = ? ORDER BY t.name`
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Counts returns every tag used by a visible snippet, the most used first.
+func (m *TagModel) Counts() ([]*TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	JOIN snippets s ON s.id = st.snippet_id
+	WHERE ` + visibleSnippets + `
+	GROUP BY t.name ORDER BY COUNT(*) DESC, t.name`
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*TagCount{}
+
+	for rows.Next() {
+		c := &TagCount{}
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Snippets returns a page of the visible snippets with the tag, newest first.
+func (m *TagModel) Snippets(tag string, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumnsOf("s") + `
+	FROM snippets s
+	JOIN snippet_tags st ON st.snippet_id = s.id
+	JOIN tags t ON t.id = st.tag_id
+	WHERE t.name = ? AND ` + visibleSnippets + `
+	ORDER BY s.id DESC LIMIT ? OFFSET ?`
+	return (&SnippetModel{DB: m.DB}).query(stmt, tag, limit, offset)
+}
+
+// Count returns how many visible snippets have the tag.
+func (m *TagModel) Count(tag string) (int, error) {
+	stmt := `SELECT COUNT(*) FROM snippets s
+	JOIN snippet_tags st ON st.snippet_id = s.id
+	JOIN tags t ON t.id = st.tag_id
+	WHERE t.name = ? AND ` + visibleSnippets
+	var count int
+	err := m.DB.QueryRow(stmt, tag).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+// Token is an API token used by non-browser clients such as cmd/snippet.
+// Only the SHA-256 hash is stored; the plaintext is shown to the user once.
+type Token struct {
+	Plaintext string
+	Hash []byte
+	UserID int
+	Expiry time.Time
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+func generateToken(userID int, ttl time.Duration) (*Token, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		UserID: userID,
+		Expiry: time.Now().Add(ttl),
+	}
+
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+
+	return token, nil
+}
+
+// New replaces any existing tokens of the user with a freshly generated one.
+func (m *TokenModel) New(userID int, ttl time.Duration) (*Token, error) {
+	token, err := generateToken(userID, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = m.DeleteAllForUser(userID); err != nil {
+		return nil, err
+	}
+
+	stmt := `INSERT INTO tokens (hash, user_id, expiry, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
+	_, err = m.DB.Exec(stmt, token.Hash, token.UserID, token.Expiry.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// UserID returns the id of the user owning the given plaintext token.
+func (m *TokenModel) UserID(plaintext string) (int, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	var userID int
+	stmt := `SELECT user_id FROM tokens WHERE hash = ? AND expiry > UTC_TIMESTAMP()`
+	err := m.DB.QueryRow(stmt, hash[:]).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+func (m *TokenModel) DeleteAllForUser(userID int) error {
+	stmt := `DELETE FROM tokens WHERE user_id = ?`
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
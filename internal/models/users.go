@@ -4,17 +4,30 @@ import (
 	"database/sql"
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Roles a user can have. Moderators can remove snippets, admins can also
+// disable accounts.
+const (
+	RoleUser = "user"
+	RoleModerator = "moderator"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID int
 	Name string
 	Email string
 	HashedPassword []byte
+	EmailVerified bool
+	TOTPEnabled bool
+	Role string
+	Disabled bool
 	Created time.Time
 }
 
@@ -22,27 +35,37 @@ type UserModel struct {
 	DB *sql.DB
 }
 
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), 12)
+	return hash
+})
+
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	stmt := `INSERT INTO users(name, email, hashed_password, created) VALUES (?, ?, ?, UTC_TIMESTAMP())`
 
-	_, err = m.DB.Exec(stmt, name, email, hashedPassword)
+	result, err := m.DB.Exec(stmt, name, email, hashedPassword)
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
+				return 0, ErrDuplicateEmail
 			}
 		}
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
@@ -53,6 +76,9 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashed_password)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// spend as long as a real check would, so response times don't
+			// reveal which emails have an account
+			bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
 			return 0, ErrInvalidCredentials
 		} else {
 			return 0, err
@@ -71,6 +97,23 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, nil
 }
 
+// Role returns the role of the user, or ErrNoRecord if there is no such user or
+// the account is disabled.
+func (m *UserModel) Role(id int) (string, error) {
+	var role string
+	stmt := `SELECT role FROM users WHERE id = ? AND disabled = FALSE`
+	err := m.DB.QueryRow(stmt, id).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
 func (m *UserModel) Exists(id int) (bool, error) {
 	var exists bool
 	stmt := `SELECT EXISTS(SELECT true from users where id=?)`
@@ -90,8 +133,8 @@ func (m *UserModel) Get(id int) (*User, error) {
 	var user User
 	user.ID = id
 
-	stmt := `SELECT name, email, created from users where id=?`
-	err := m.DB.QueryRow(stmt, id).Scan(&user.Name, &user.Email, &user.Created)
+	stmt := `SELECT name, email, email_verified, totp_secret IS NOT NULL, role, disabled, created from users where id=?`
+	err := m.DB.QueryRow(stmt, id).Scan(&user.Name, &user.Email, &user.EmailVerified, &user.TOTPEnabled, &user.Role, &user.Disabled, &user.Created)
 	if err != nil {
 		return nil, ErrNoRecord
 	}
@@ -133,3 +176,235 @@ func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string)
 
 	return nil
 }
+
+// VerifyEmail marks the email of the user as verified. It only succeeds while
+// the address is still the one the token was issued for and is not verified
+// yet, which makes every verification token single-use.
+func (m *UserModel) VerifyEmail(id int, email string) error {
+	stmt := `UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ? AND email_verified = FALSE`
+	result, err := m.DB.Exec(stmt, id, email)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	var user User
+	user.Email = email
+
+	stmt := `SELECT id, name, email_verified, totp_secret IS NOT NULL, role, disabled, created from users where email=?`
+	err := m.DB.QueryRow(stmt, email).Scan(&user.ID, &user.Name, &user.EmailVerified, &user.TOTPEnabled, &user.Role, &user.Disabled, &user.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the current
+// password of the user.
+func (m *UserModel) CheckPassword(id int, password string) error {
+	var hashed_password []byte
+	stmt := `select hashed_password from users where id=?`
+	err := m.DB.QueryRow(stmt, id).Scan(&hashed_password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashed_password, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TOTPSecret returns the TOTP secret of the user, or ErrNoRecord if two-factor
+// authentication isn't enabled.
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	var secret sql.NullString
+	stmt := `SELECT totp_secret FROM users WHERE id = ?`
+	err := m.DB.QueryRow(stmt, id).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrNoRecord
+		} else {
+			return "", err
+		}
+	}
+
+	if !secret.Valid {
+		return "", ErrNoRecord
+	}
+
+	return secret.String, nil
+}
+
+// SetTOTPSecret enables two-factor authentication, or disables it when secret
+// is empty.
+func (m *UserModel) SetTOTPSecret(id int, secret string) error {
+	stmt := `UPDATE users SET totp_secret = NULLIF(?, ''), totp_last_step = 0 WHERE id = ?`
+	_, err := m.DB.Exec(stmt, secret, id)
+	return err
+}
+
+// UseTOTPStep records that the code of the TOTP time step was accepted. It
+// reports false if that step, or a later one, was used already, so a code
+// can't be replayed while it is still valid.
+func (m *UserModel) UseTOTPStep(id int, step int64) (bool, error) {
+	stmt := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+	result, err := m.DB.Exec(stmt, step, id, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// UpdateProfile changes the name and email of the user. A changed email has to
+// be verified again.
+func (m *UserModel) UpdateProfile(id int, name, email string) error {
+	// assignments are evaluated left to right, so email_verified still sees
+	// the old address
+	stmt := `UPDATE users SET email_verified = email_verified AND email = ?, name = ?, email = ? WHERE id = ?`
+	_, err := m.DB.Exec(stmt, email, name, email, id)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the user and everything tied to the account. Their snippets
+// are deleted too, unless keepSnippets is set, in which case the public ones
+// stay up without an owner like the snippets from before accounts existed.
+func (m *UserModel) Delete(id int, keepSnippets bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the stars of the user go with the account, so take them off the counts
+	stmt := `UPDATE snippets s JOIN stars st ON st.snippet_id = s.id SET s.stars = s.stars - 1 WHERE st.user_id = ?`
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return err
+	}
+
+	// only public snippets can be kept, nobody could ever see or delete
+	// private or hidden ones again once they have no owner
+	stmt = `DELETE FROM snippets WHERE user_id = ?`
+	if keepSnippets {
+		stmt = `DELETE FROM snippets WHERE user_id = ? AND (private = TRUE OR hidden = TRUE)`
+	}
+	if _, err := tx.Exec(stmt, id); err != nil {
+		return err
+	}
+
+	if keepSnippets {
+		if _, err := tx.Exec(`UPDATE snippets SET user_id = 0 WHERE user_id = ?`, id); err != nil {
+			return err
+		}
+	}
+
+	// the audit log has no foreign key so entries outlive lockouts of
+	// unknown accounts, but it still holds the addresses the user came from
+	if _, err := tx.Exec(`DELETE FROM audit_log WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+
+	// tokens, passkeys, identities and the rest cascade
+	result, err := tx.Exec(`DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return tx.Commit()
+}
+
+// All returns a page of users, newest first.
+func (m *UserModel) All(limit, offset int) ([]*User, error) {
+	stmt := `SELECT id, name, email, email_verified, totp_secret IS NOT NULL, role, disabled, created FROM users
+	ORDER BY id DESC LIMIT ? OFFSET ?`
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		u := &User{}
+		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.EmailVerified, &u.TOTPEnabled, &u.Role, &u.Disabled, &u.Created)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (m *UserModel) SetDisabled(id int, disabled bool) error {
+	stmt := `UPDATE users SET disabled = ? WHERE id = ?`
+	result, err := m.DB.Exec(stmt, disabled, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DailyViews is how many times a snippet was viewed on a day (UTC).
+type DailyViews struct {
+	Day time.Time
+	Views int
+}
+
+type ViewModel struct {
+	DB *sql.DB
+}
+
+// Add records the views counted on the day, keyed by snippet ID, on both the
+// daily totals and the counter on the snippet. Snippets deleted in the
+// meantime are skipped.
+func (m *ViewModel) Add(day time.Time, counts map[int]int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, n := range counts {
+		stmt := `INSERT INTO snippet_views (snippet_id, day, views) SELECT id, ?, ? FROM snippets WHERE id = ?
+		ON DUPLICATE KEY UPDATE views = snippet_views.views + VALUES(views)`
+		if _, err := tx.Exec(stmt, day.UTC().Format(time.DateOnly), n, id); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE snippets SET views = views + ? WHERE id = ?`, n, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Daily returns the views of the snippet for each of the last days days,
+// oldest first and ending today, with the days nobody looked at it as zero.
+func (m *ViewModel) Daily(snippetID, days int) ([]*DailyViews, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, 1-days)
+
+	stmt := `SELECT day, views FROM snippet_views WHERE snippet_id = ? AND day >= ?`
+	rows, err := m.DB.Query(stmt, snippetID, first.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counted := map[string]int{}
+	for rows.Next() {
+		var day time.Time
+		var views int
+		if err := rows.Scan(&day, &views); err != nil {
+			return nil, err
+		}
+		counted[day.Format(time.DateOnly)] = views
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	daily := make([]*DailyViews, days)
+	for i := range daily {
+		day := first.AddDate(0, 0, i)
+		daily[i] = &DailyViews{Day: day, Views: counted[day.Format(time.DateOnly)]}
+	}
+
+	return daily, nil
+}
@@ -0,0 +1,133 @@
+// Package secrets looks for credentials in text, like API keys and private
+// keys pasted into a snippet by accident.
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Rule finds one kind of secret in a single line.
+type Rule interface {
+	Name() string
+	Match(line string) bool
+}
+
+// Finding is a line a rule matched. Lines are numbered from 1.
+type Finding struct {
+	Line int
+	Rule string
+}
+
+type Scanner struct {
+	rules []Rule
+}
+
+func New(rules ...Rule) *Scanner {
+	return &Scanner{rules: rules}
+}
+
+// Default returns a scanner with the built-in rules.
+func Default() *Scanner {
+	return New(
+		Pattern("AWS access key", `\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+		Pattern("AWS secret key", `(?i)aws.{0,20}(secret|key).{0,20}['"=:\s][0-9a-zA-Z/+]{40}\b`),
+		Pattern("private key", `-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`),
+		Pattern("GitHub token", `\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{60,})\b`),
+		Entropy(32, 4.5),
+	)
+}
+
+// Scan returns what the rules found, in line order. A line matched by several
+// rules is reported once per rule.
+func (s *Scanner) Scan(text string) []Finding {
+	var findings []Finding
+	for i, line := range strings.Split(text, "\n") {
+		for _, rule := range s.rules {
+			if rule.Match(line) {
+				findings = append(findings, Finding{Line: i + 1, Rule: rule.Name()})
+			}
+		}
+	}
+	return findings
+}
+
+type patternRule struct {
+	name string
+	re *regexp.Regexp
+}
+
+// Pattern is a rule matching lines that contain the regular expression.
+func Pattern(name, expr string) Rule {
+	return &patternRule{name: name, re: regexp.MustCompile(expr)}
+}
+
+func (r *patternRule) Name() string { return r.name }
+func (r *patternRule) Match(line string) bool { return r.re.MatchString(line) }
+
+type entropyRule struct {
+	minLength int
+	minEntropy float64
+}
+
+// Entropy is a rule matching words of at least minLength characters from the
+// base64 alphabet that look random, with at least minEntropy bits of Shannon
+// entropy per character. Generated tokens usually have 5 or more, hex hashes
+// can't exceed 4 and English words stay well below.
+func Entropy(minLength int, minEntropy float64) Rule {
+	return &entropyRule{minLength: minLength, minEntropy: minEntropy}
+}
+
+var tokenRX = regexp.MustCompile(`[A-Za-z0-9+/=_\-]+`)
+
+func (r *entropyRule) Name() string { return "high-entropy string" }
+
+func (r *entropyRule) Match(line string) bool {
+	for _, word := range tokenRX.FindAllString(line, -1) {
+		if len(word) >= r.minLength && mixed(word) && shannon(word) >= r.minEntropy {
+			return true
+		}
+	}
+	return false
+}
+
+// mixed reports whether the word has upper and lower case letters and digits
+// and keeps switching between them, like random strings do about 60% of the
+// time. CamelCase identifiers and paths have long runs of one kind.
+func mixed(word string) bool {
+	var upper, lower, digit bool
+	var switches int
+	previous := -1
+	for _, c := range word {
+		class := 3
+		switch {
+		case 'A' <= c && c <= 'Z':
+			upper, class = true, 0
+		case 'a' <= c && c <= 'z':
+			lower, class = true, 1
+		case '0' <= c && c <= '9':
+			digit, class = true, 2
+		}
+		if previous >= 0 && class != previous {
+			switches++
+		}
+		previous = class
+	}
+	return upper && lower && digit && switches*100 >= len(word)*45
+}
+
+func shannon(s string) float64 {
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+
+	var entropy float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,54 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+type payload struct {
+	UserID int
+	Email string
+}
+
+func TestSigner(t *testing.T) {
+	s := New([]byte("test key"))
+
+	token, err := s.Sign("verify-email", payload{1, "a@example.com"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		var p payload
+		err := s.Verify("verify-email", token, &p)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p, payload{1, "a@example.com"})
+	})
+
+	t.Run("Wrong purpose", func(t *testing.T) {
+		var p payload
+		assert.Equal(t, s.Verify("reset-password", token, &p), ErrInvalidToken)
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		var p payload
+		assert.Equal(t, New([]byte("other key")).Verify("verify-email", token, &p), ErrInvalidToken)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		var p payload
+		assert.Equal(t, s.Verify("verify-email", "x"+token, &p), ErrInvalidToken)
+		assert.Equal(t, s.Verify("verify-email", "garbage", &p), ErrInvalidToken)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		expired, err := s.Sign("verify-email", payload{1, "a@example.com"}, -time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p payload
+		assert.Equal(t, s.Verify("verify-email", expired, &p), ErrExpiredToken)
+	})
+}
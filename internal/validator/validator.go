@@ -65,3 +65,34 @@ var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// TagRX allows lower case letters, digits and a few symbols used in names
+// like c++, c# or node.js, starting with a letter or digit.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#.\-]*$`)
+
+func MaxItems[T any](values []T, n int) bool {
+	return len(values) <= n
+}
+
+func AllMaxChars(values []string, n int) bool {
+	for _, value := range values {
+		if !MaxChars(value, n) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func AllMatch(values []string, rx *regexp.Regexp) bool {
+	for _, value := range values {
+		if !rx.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilenameRX allows plain file names like main.go or go.mod, without any path.
+var FilenameRX = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._-]*$`)
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+
+	"caniteySnippetBox/internal/assert"
+)
+
+func TestMaxItems(t *testing.T) {
+	tests := []struct {
+		name string
+		values []string
+		n int
+		want bool
+	}{
+		{name: "Empty", values: []string{}, n: 0, want: true},
+		{name: "Under", values: []string{"a"}, n: 2, want: true},
+		{name: "At the limit", values: []string{"a", "b"}, n: 2, want: true},
+		{name: "Over", values: []string{"a", "b", "c"}, n: 2, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, MaxItems(tt.values, tt.n), tt.want)
+		})
+	}
+}
+
+func TestAllMaxChars(t *testing.T) {
+	tests := []struct {
+		name string
+		values []string
+		want bool
+	}{
+		{name: "Empty", values: []string{}, want: true},
+		{name: "All short", values: []string{"go", "sql"}, want: true},
+		{name: "Counts runes", values: []string{"ééé"}, want: true},
+		{name: "One too long", values: []string{"go", "toolong"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, AllMaxChars(tt.values, 3), tt.want)
+		})
+	}
+}
+
+func TestAllMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		values []string
+		want bool
+	}{
+		{name: "Empty", values: []string{}, want: true},
+		{name: "All match", values: []string{"go", "c++", "c#", "node.js", "objective-c"}, want: true},
+		{name: "One does not", values: []string{"go", "go lang"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, AllMatch(tt.values, TagRX), tt.want)
+		})
+	}
+}
+
+func TestTagRX(t *testing.T) {
+	tests := []struct {
+		tag string
+		want bool
+	}{
+		{tag: "go", want: true},
+		{tag: "c++", want: true},
+		{tag: "c#", want: true},
+		{tag: "node.js", want: true},
+		{tag: "3d", want: true},
+		{tag: "", want: false},
+		{tag: "Go", want: false},
+		{tag: "-go", want: false},
+		{tag: "#go", want: false},
+		{tag: "go/lang", want: false},
+		{tag: "go_lang", want: false},
+		{tag: strings.Repeat("a", 10) + " ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			assert.Equal(t, TagRX.MatchString(tt.tag), tt.want)
+		})
+	}
+}
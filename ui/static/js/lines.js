@@ -0,0 +1,59 @@
+// Highlights the lines named in the fragment, #L10 or #L10-L20, prefixed with
+// the file name when the snippet has several. Shift-clicking a line number
+// extends the current selection into a range.
+var linePattern = /^#(.*?-)?L(\d+)(?:-L(\d+))?$/;
+
+function parseLines(hash) {
+	var match = linePattern.exec(decodeURIComponent(hash));
+	if (!match) {
+		return null;
+	}
+	var from = parseInt(match[2], 10);
+	var to = match[3] ? parseInt(match[3], 10) : from;
+	return {prefix: match[1] || "", from: Math.min(from, to), to: Math.max(from, to)};
+}
+
+function highlightLines() {
+	var selected = document.querySelectorAll("pre.lines .selected");
+	for (var i = 0; i < selected.length; i++) {
+		selected[i].classList.remove("selected");
+	}
+
+	var range = parseLines(window.location.hash);
+	if (!range) {
+		return;
+	}
+	for (var n = range.from; n <= range.to; n++) {
+		var line = document.getElementById(range.prefix + "L" + n);
+		if (!line) {
+			break;
+		}
+		line.classList.add("selected");
+	}
+
+	var first = document.getElementById(range.prefix + "L" + range.from);
+	if (first) {
+		first.scrollIntoView({block: "center"});
+	}
+}
+
+document.addEventListener("click", function(event) {
+	var link = event.target.closest ? event.target.closest("pre.lines a.num") : null;
+	if (!link || !event.shiftKey) {
+		return;
+	}
+
+	var current = parseLines(window.location.hash);
+	var clicked = parseLines(link.getAttribute("href"));
+	if (!current || !clicked || current.prefix != clicked.prefix) {
+		return;
+	}
+
+	event.preventDefault();
+	var from = Math.min(current.from, clicked.from);
+	var to = Math.max(current.from, clicked.from);
+	window.location.hash = clicked.prefix + "L" + from + (to > from ? "-L" + to : "");
+});
+
+window.addEventListener("hashchange", highlightLines);
+highlightLines();
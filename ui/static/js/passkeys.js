@@ -0,0 +1,124 @@
+// WebAuthn ceremonies for /account/passkeys and the login page. Buttons opt in
+// with a data-passkey attribute and carry the CSRF token for the JSON requests.
+
+function bufferFromBase64url(value) {
+	var base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+	while (base64.length % 4) {
+		base64 += "=";
+	}
+	var binary = atob(base64);
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes.buffer;
+}
+
+function base64urlFromBuffer(buffer) {
+	var bytes = new Uint8Array(buffer);
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function postJSON(url, csrfToken, body) {
+	return fetch(url, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {"Content-Type": "application/json", "X-CSRF-Token": csrfToken},
+		body: body === undefined ? undefined : JSON.stringify(body)
+	}).then(function (response) {
+		return response.json().then(function (data) {
+			if (!response.ok) {
+				throw new Error(data.error || response.statusText);
+			}
+			return data;
+		});
+	});
+}
+
+function showPasskeyError(button, err) {
+	var target = document.getElementById(button.getAttribute("data-passkey-error"));
+	if (target) {
+		target.textContent = err.message || String(err);
+		target.hidden = false;
+	}
+}
+
+function registerPasskey(button) {
+	var csrfToken = button.getAttribute("data-csrf-token");
+	var nameInput = document.getElementById("passkey-name");
+
+	postJSON("/account/passkeys/register/begin", csrfToken).then(function (options) {
+		var publicKey = options.publicKey;
+		publicKey.challenge = bufferFromBase64url(publicKey.challenge);
+		publicKey.user.id = bufferFromBase64url(publicKey.user.id);
+		(publicKey.excludeCredentials || []).forEach(function (c) {
+			c.id = bufferFromBase64url(c.id);
+		});
+		return navigator.credentials.create({publicKey: publicKey});
+	}).then(function (credential) {
+		var name = nameInput ? nameInput.value : "";
+		return postJSON("/account/passkeys/register/finish?name=" + encodeURIComponent(name), csrfToken, {
+			id: credential.id,
+			rawId: base64urlFromBuffer(credential.rawId),
+			type: credential.type,
+			response: {
+				attestationObject: base64urlFromBuffer(credential.response.attestationObject),
+				clientDataJSON: base64urlFromBuffer(credential.response.clientDataJSON)
+			}
+		});
+	}).then(function (data) {
+		window.location = data.redirect;
+	}).catch(function (err) {
+		showPasskeyError(button, err);
+	});
+}
+
+function loginWithPasskey(button) {
+	var csrfToken = button.getAttribute("data-csrf-token");
+
+	postJSON("/user/login/passkey/begin", csrfToken).then(function (options) {
+		var publicKey = options.publicKey;
+		publicKey.challenge = bufferFromBase64url(publicKey.challenge);
+		(publicKey.allowCredentials || []).forEach(function (c) {
+			c.id = bufferFromBase64url(c.id);
+		});
+		return navigator.credentials.get({publicKey: publicKey});
+	}).then(function (assertion) {
+		return postJSON("/user/login/passkey/finish", csrfToken, {
+			id: assertion.id,
+			rawId: base64urlFromBuffer(assertion.rawId),
+			type: assertion.type,
+			response: {
+				authenticatorData: base64urlFromBuffer(assertion.response.authenticatorData),
+				clientDataJSON: base64urlFromBuffer(assertion.response.clientDataJSON),
+				signature: base64urlFromBuffer(assertion.response.signature),
+				userHandle: assertion.response.userHandle ? base64urlFromBuffer(assertion.response.userHandle) : null
+			}
+		});
+	}).then(function (data) {
+		window.location = data.redirect;
+	}).catch(function (err) {
+		showPasskeyError(button, err);
+	});
+}
+
+var passkeyButtons = document.querySelectorAll("[data-passkey]");
+for (var i = 0; i < passkeyButtons.length; i++) {
+	var button = passkeyButtons[i];
+	if (!window.PublicKeyCredential) {
+		button.disabled = true;
+		continue;
+	}
+	button.addEventListener("click", function (event) {
+		event.preventDefault();
+		if (event.currentTarget.getAttribute("data-passkey") == "register") {
+			registerPasskey(event.currentTarget);
+		} else {
+			loginWithPasskey(event.currentTarget);
+		}
+	});
+}